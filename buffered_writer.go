@@ -0,0 +1,165 @@
+package eazy
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type (
+	// BufferedWriter is a Writer wrapper safe for concurrent use.
+	// It batches small Writes and flushes them
+	// when buffered data reaches the threshold
+	// or flush interval passes since the first unflushed Write,
+	// whichever comes first.
+	//
+	// It trades the Writer guarantee of one compressed Write per Write
+	// for fewer underlaying Writes while limiting how much data
+	// can be lost in case of a crash.
+	BufferedWriter struct {
+		mu sync.Mutex
+
+		w *Writer
+
+		interval time.Duration
+		t        *time.Timer
+		armed    bool
+
+		err    error // background flush error
+		closed bool
+	}
+)
+
+// ErrClosed is returned when using a closed writer or reader.
+var ErrClosed = errors.New("closed")
+
+// NewBufferedWriter creates BufferedWriter flushing w when
+// buffered compressed data reaches threshold bytes
+// or flushInterval passes since the first unflushed Write.
+//
+// threshold <= 0 disables size based flushing.
+// flushInterval <= 0 disables time based flushing.
+//
+// w.FlushThreshold is set to threshold, w must not be used directly after this call.
+func NewBufferedWriter(w *Writer, threshold int, flushInterval time.Duration) *BufferedWriter {
+	if threshold <= 0 {
+		threshold = -1
+	}
+
+	w.FlushThreshold = threshold
+
+	return &BufferedWriter{
+		w:        w,
+		interval: flushInterval,
+	}
+}
+
+// Write compresses p and buffers it.
+// It returns background flush error if there was one since the last call.
+func (b *BufferedWriter) Write(p []byte) (n int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return 0, ErrClosed
+	}
+
+	if err = b.err; err != nil {
+		b.err = nil
+		return 0, err
+	}
+
+	n, err = b.w.Write(p)
+
+	b.arm()
+
+	return n, err
+}
+
+// WriteBreak writes Break marker. See Writer.WriteBreak.
+func (b *BufferedWriter) WriteBreak() (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	err = b.w.WriteBreak()
+
+	b.arm()
+
+	return err
+}
+
+// Flush flushes buffered data immediately.
+func (b *BufferedWriter) Flush() (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.flush()
+}
+
+// Close stops the background flushing and flushes buffered data.
+func (b *BufferedWriter) Close() (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+
+	b.closed = true
+
+	if b.t != nil {
+		b.t.Stop()
+		b.armed = false
+	}
+
+	return b.flush()
+}
+
+func (b *BufferedWriter) flush() (err error) {
+	err = b.w.Flush()
+
+	if err == nil {
+		err = b.err
+	}
+
+	b.err = nil
+
+	return err
+}
+
+// arm starts the timer if there is buffered data. b.mu must be held.
+func (b *BufferedWriter) arm() {
+	if b.interval <= 0 || b.armed || len(b.w.b) == 0 {
+		return
+	}
+
+	b.armed = true
+
+	if b.t == nil {
+		b.t = time.AfterFunc(b.interval, b.tick)
+	} else {
+		b.t.Reset(b.interval)
+	}
+}
+
+func (b *BufferedWriter) tick() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.armed {
+		return
+	}
+
+	b.armed = false
+
+	err := b.w.Flush()
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+
+	b.arm()
+}
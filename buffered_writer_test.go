@@ -0,0 +1,108 @@
+package eazy
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lockedBuf struct {
+	mu sync.Mutex
+	b  Buf
+	n  int // writes
+}
+
+func TestBufferedWriterInterval(t *testing.T) {
+	var b lockedBuf
+
+	w := NewBufferedWriter(NewWriter(&b, 1024, 32), 1024, 10*time.Millisecond)
+
+	_, err := w.Write([]byte("first"))
+	assert.NoError(t, err)
+
+	_, err = w.Write([]byte("second"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, b.writes())
+
+	assert.Eventually(t, func() bool { return b.writes() == 1 }, time.Second, time.Millisecond)
+
+	_, err = w.Write([]byte("third"))
+	assert.NoError(t, err)
+
+	err = w.Close()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, b.writes())
+
+	_, err = w.Write([]byte("closed"))
+	assert.ErrorIs(t, err, ErrClosed)
+
+	p := make([]byte, 100)
+
+	n, err := NewReaderBytes(b.b).Read(p)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "firstsecondthird", string(p[:n]))
+}
+
+func TestBufferedWriterConcurrent(t *testing.T) {
+	const N, M = 8, 100
+
+	var b lockedBuf
+
+	w := NewBufferedWriter(NewWriter(&b, 1024, 32), 256, time.Millisecond)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < M; j++ {
+				_, err := fmt.Fprintf(w, "producer %d message %3d\n", i, j)
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	err := w.Close()
+	require.NoError(t, err)
+
+	assert.Less(t, b.writes(), N*M/4)
+
+	r := NewReaderBytes(b.b)
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	for i := 0; i < N; i++ {
+		for j := 0; j < M; j++ {
+			assert.Contains(t, string(data), fmt.Sprintf("producer %d message %3d\n", i, j))
+		}
+	}
+}
+
+func (b *lockedBuf) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.n++
+
+	return b.b.Write(p)
+}
+
+func (b *lockedBuf) writes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.n
+}
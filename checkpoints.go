@@ -0,0 +1,214 @@
+package eazy
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+type (
+	// Checkpoint is a saved Reader state decoding can be resumed from.
+	// It includes a copy of the window, so it takes about block size bytes of memory.
+	Checkpoint struct {
+		In  int64 // compressed stream offset
+		Out int64 // decompressed stream offset
+
+		window []byte // nil before the first stream header
+		pos    int64
+		ver    int
+
+		state    byte
+		off, len int
+	}
+
+	// Checkpoints is an in-memory random access index for any existing stream.
+	// It's the approach zlib zran example uses for gzip files,
+	// no reset points or other format features are needed.
+	Checkpoints struct {
+		List []Checkpoint
+
+		// Size is the decompressed stream size.
+		Size int64
+	}
+
+	// ReaderAt reads random ranges of decompressed data
+	// by resuming decoding from the nearest Checkpoint.
+	// It's safe for concurrent use.
+	ReaderAt struct {
+		r  io.ReaderAt
+		cp *Checkpoints
+
+		pool sync.Pool
+	}
+)
+
+// BuildCheckpoints decodes the whole stream from r once
+// and saves Reader state each time after at least every bytes are decoded.
+//
+// Break markers are skipped, Out offsets are counted as if there were no markers.
+func BuildCheckpoints(r io.Reader, every int64) (*Checkpoints, error) {
+	if every <= 0 {
+		return nil, ErrBadOption
+	}
+
+	d := NewReader(r)
+	buf := make([]byte, 64*KiB)
+
+	cps := &Checkpoints{}
+	cps.List = append(cps.List, d.checkpoint(0))
+
+	var out int64
+	next := every
+
+	for {
+		p := buf
+		if int64(len(p)) > next-out {
+			p = p[:next-out]
+		}
+
+		n, err := d.Read(p)
+		out += int64(n)
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil && !errors.Is(err, ErrBreak) {
+			return nil, err
+		}
+
+		if out >= next {
+			cps.List = append(cps.List, d.checkpoint(out))
+			next = out + every
+		}
+	}
+
+	cps.Size = out
+
+	return cps, nil
+}
+
+// NewReaderAt creates ReaderAt over the compressed stream r indexed by cp.
+func NewReaderAt(r io.ReaderAt, cp *Checkpoints) *ReaderAt {
+	return &ReaderAt{
+		r:  r,
+		cp: cp,
+	}
+}
+
+// Size returns the decompressed stream size.
+func (ra *ReaderAt) Size() int64 { return ra.cp.Size }
+
+// ReadAt implements io.ReaderAt.
+func (ra *ReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, ErrBadOption
+	}
+
+	if off >= ra.cp.Size {
+		return 0, io.EOF
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	list := ra.cp.List
+
+	k := sort.Search(len(list), func(i int) bool {
+		return list[i].Out > off
+	}) - 1
+
+	cp := &list[k]
+
+	r, ok := ra.pool.Get().(*Reader)
+	if !ok {
+		r = NewReader(nil)
+	}
+
+	defer ra.pool.Put(r)
+
+	r.Reset(io.NewSectionReader(ra.r, cp.In, math.MaxInt64-cp.In))
+	r.restore(cp)
+
+	// skipped data is decoded into the window, not into p
+	for skip := off - cp.Out; skip > 0; {
+		max := math.MaxInt
+		if skip < int64(max) {
+			max = int(skip)
+		}
+
+		q, err := r.decode(max)
+		skip -= int64(len(q))
+
+		if errors.Is(err, ErrBreak) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return readFull(r, p)
+}
+
+// readFull reads till p is full skipping Break markers.
+func readFull(r *Reader, p []byte) (n int, err error) {
+	for n < len(p) {
+		var m int
+
+		m, err = r.Read(p[n:])
+		n += m
+
+		switch {
+		case errors.Is(err, ErrBreak):
+		case errors.Is(err, io.EOF) && n < len(p):
+			return n, io.EOF
+		case err != nil && n < len(p):
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func (r *Reader) checkpoint(out int64) Checkpoint {
+	cp := Checkpoint{
+		In:  r.boff + int64(r.i),
+		Out: out,
+
+		pos: r.pos,
+		ver: r.d.Ver,
+
+		state: r.state,
+		off:   r.off,
+		len:   r.len,
+	}
+
+	if len(r.block) != 0 {
+		cp.window = append([]byte{}, r.block...)
+	}
+
+	return cp
+}
+
+func (r *Reader) restore(cp *Checkpoint) {
+	if len(cp.window) != 0 {
+		r.window(len(cp.window))
+		copy(r.block, cp.window)
+	} else {
+		r.block = r.block[:0]
+	}
+
+	r.pos = cp.pos
+	r.d.Ver = cp.ver
+
+	r.state = cp.state
+	r.off = cp.off
+	r.len = cp.len
+
+	r.boff = cp.In
+	r.out = cp.Out
+}
@@ -0,0 +1,99 @@
+package eazy
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoints(t *testing.T) {
+	data := testLogData(1 << 20)
+
+	var b Buf
+
+	w := NewWriter(&b, 16*KiB, 1024)
+
+	for i := 0; i < len(data); i += 1000 {
+		end := i + 1000
+		if end > len(data) {
+			end = len(data)
+		}
+
+		_, err := w.Write(data[i:end])
+		require.NoError(t, err)
+
+		if i%7000 == 0 {
+			err = w.WriteBreak()
+			require.NoError(t, err)
+		}
+	}
+
+	cps, err := BuildCheckpoints(&BufReader{Buf: b}, 64*KiB)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), cps.Size)
+	assert.Len(t, cps.List, len(data)/(64*KiB)+1)
+
+	ra := NewReaderAt(bytes.NewReader(b), cps)
+
+	var wg sync.WaitGroup
+
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			rnd := rand.New(rand.NewSource(int64(g)))
+			p := make([]byte, 10*KiB)
+
+			for i := 0; i < 50; i++ {
+				off := rnd.Int63n(int64(len(data)))
+				l := rnd.Intn(len(p))
+
+				n, err := ra.ReadAt(p[:l], off)
+
+				exp := data[off:]
+				if len(exp) > l {
+					exp = exp[:l]
+				}
+
+				if len(exp) < l {
+					assert.ErrorIs(t, err, io.EOF)
+				} else {
+					assert.NoError(t, err)
+				}
+
+				assert.Equal(t, len(exp), n)
+				assert.True(t, bytes.Equal(exp, p[:n]), "off %x  len %x", off, l)
+			}
+		}(g)
+	}
+
+	wg.Wait()
+
+	n, err := ra.ReadAt(make([]byte, 10), int64(len(data)-5))
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 5, n)
+
+	n, err = ra.ReadAt(make([]byte, 10), int64(len(data)))
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, n)
+
+	// past a checkpoint
+
+	n, err = ra.ReadAt(nil, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	p := make([]byte, 1)
+
+	n, err = ra.ReadAt(p, 64*KiB-1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, data[64*KiB-1], p[0])
+}
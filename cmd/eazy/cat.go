@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"tlog.app/go/eazy"
+)
+
+func (a *app) cat(args []string) int {
+	fs := flag.NewFlagSet("eazy cat", flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eazy cat [-f] [file ...]\n\n")
+		fs.PrintDefaults()
+	}
+
+	follow := fs.Bool("f", false, "follow the file as it grows, reopen it if it's rotated or truncated")
+	poll := fs.Duration("poll", 250*time.Millisecond, "follow mode poll interval")
+	a.limitFlag(fs)
+
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	if *follow {
+		if len(files) != 1 || files[0] == "-" {
+			return a.fail("", errors.New("follow mode needs exactly one file"))
+		}
+
+		err = a.follow(files[0], *poll)
+		if err != nil {
+			return a.fail(files[0], err)
+		}
+
+		return 0
+	}
+
+	code := 0
+
+	for _, name := range files {
+		err = a.catFile(name)
+		if err != nil {
+			code = a.fail(name, err)
+		}
+	}
+
+	return code
+}
+
+func (a *app) catFile(name string) (err error) {
+	if name == "-" {
+		return a.decompressStream(a.stdout, a.stdin)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer closeIt(f, &err, "close")
+
+	return a.decompressStream(a.stdout, f)
+}
+
+// follow prints the file decompressed and then waits for more data as tail -f does.
+// If the file is replaced or truncated it's reopened and read from the start.
+// It returns nil when a.ctx is canceled.
+func (a *app) follow(name string, poll time.Duration) (err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		closeIt(f, &err, "close")
+	}()
+
+	cr := &countReader{Reader: f}
+
+	r, err := eazy.NewReaderOptions(cr, a.opts)
+	if err != nil {
+		return err
+	}
+
+	defer r.Release()
+
+	r.Follow = true
+
+	t := time.NewTicker(poll)
+	defer t.Stop()
+
+	for {
+		err = a.copyOut(r)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-a.ctx.Done():
+			return nil
+		case <-t.C:
+		}
+
+		reopen, err := replaced(f, name, cr.n)
+		if err != nil {
+			return err
+		}
+
+		if !reopen {
+			continue
+		}
+
+		nf, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// written to the old file before it was rotated
+		err = a.copyOut(r)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+
+		_ = f.Close()
+
+		f = nf
+		cr.Reader, cr.n = f, 0
+
+		r.Reset(cr)
+	}
+}
+
+// copyOut writes all available data from r to stdout.
+func (a *app) copyOut(r *eazy.Reader) error {
+	for {
+		_, err := r.WriteToContext(a.ctx, a.stdout)
+		if !errors.Is(err, eazy.ErrBreak) {
+			return err
+		}
+	}
+}
+
+// replaced reports if the file opened as f was rotated or truncated.
+// off is the number of bytes already read from f.
+func replaced(f *os.File, name string, off int64) (bool, error) {
+	cur, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	inf, err := os.Stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil // moved away, the new one is not created yet
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return !os.SameFile(cur, inf) || inf.Size() < off, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"tlog.app/go/eazy"
+)
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func TestCat(t *testing.T) {
+	dir := t.TempDir()
+
+	a1 := filepath.Join(dir, "a.ez")
+	a2 := filepath.Join(dir, "b.ez")
+
+	err := os.WriteFile(a1, encode(t, "first file\n"), 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(a2, encode(t, "second file\n"), 0o644)
+	require.NoError(t, err)
+
+	a, stdout, stderr := testApp(nil)
+	code := a.run([]string{"cat", a1, a2})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+	assert.Equal(t, "first file\nsecond file\n", stdout.String())
+
+	a, _, stderr = testApp(nil)
+	code = a.run([]string{"cat", "-f", a1, a2})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "exactly one file")
+}
+
+func TestCatFollow(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "log.ez")
+
+	data := strings.Repeat("some log message 0123456789\n", 100)
+	enc := encode(t, data)
+
+	err := os.WriteFile(name, enc[:len(enc)/2], 0o644)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var stdout, stderr syncBuffer
+
+	a := &app{
+		ctx:    ctx,
+		stdout: &stdout,
+		stderr: &stderr,
+	}
+
+	done := make(chan int)
+
+	go func() {
+		done <- a.run([]string{"cat", "-f", "-poll", "5ms", name})
+	}()
+
+	waitOutput := func(exp string) {
+		t.Helper()
+
+		assert.Eventually(t, func() bool {
+			return stdout.String() == exp
+		}, 2*time.Second, 5*time.Millisecond, "stderr: %s", stderr.String())
+	}
+
+	assert.Eventually(t, func() bool {
+		out := stdout.String()
+		return len(out) != 0 && strings.HasPrefix(data, out)
+	}, 2*time.Second, 5*time.Millisecond)
+
+	appendFile(t, name, enc[len(enc)/2:])
+
+	waitOutput(data)
+
+	// rotation
+
+	err = os.Rename(name, name+".1")
+	require.NoError(t, err)
+
+	appendFile(t, name+".1", encode(t, "last old record\n"))
+
+	err = os.WriteFile(name, encode(t, "new file\n"), 0o644)
+	require.NoError(t, err)
+
+	waitOutput(data + "last old record\nnew file\n")
+
+	// truncation
+
+	err = os.WriteFile(name, encode(t, "trunc\n"), 0o644)
+	require.NoError(t, err)
+
+	waitOutput(data + "last old record\nnew file\ntrunc\n")
+
+	cancel()
+
+	select {
+	case code := <-done:
+		assert.Equal(t, 0, code, "stderr: %s", stderr.String())
+	case <-time.After(2 * time.Second):
+		t.Fatalf("follow didn't stop")
+	}
+}
+
+func encode(t *testing.T, data string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+
+	w := eazy.NewWriter(&b, 1024, 32)
+
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+
+	return b.Bytes()
+}
+
+func appendFile(t *testing.T, name string, p []byte) {
+	t.Helper()
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+
+	_, err = f.Write(p)
+	require.NoError(t, err)
+
+	err = f.Close()
+	require.NoError(t, err)
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	defer b.mu.Unlock()
+	b.mu.Lock()
+
+	return b.b.String()
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"tlog.app/go/eazy"
+)
+
+type (
+	// element is a compressed stream element reported by elements.
+	element struct {
+		In, End int64 // compressed range including literal and meta data
+		Out     int64 // decompressed offset
+
+		Tag byte // Dumper.Debug tag: 'l', 'c', 'm' or 'p'
+		Len int  // literal or copy length, meta data length, padding size
+		Off int  // copy offset, meta tag
+
+		// Data is literal or meta data, or copy data if resolved.
+		// It's only valid until the callback returns.
+		Data []byte
+
+		Src int64 // resolved copy source decompressed offset
+	}
+
+	dumper struct {
+		w   io.Writer
+		csv *csv.Writer
+		b   []byte
+
+		format string
+		kinds  [elemKinds]bool
+		data   bool
+		limit  int // block size limit as in eazy.Options
+
+		from, to     int64 // decompressed
+		inFrom, inTo int64 // compressed
+	}
+
+	dumpJSON struct {
+		In   int64   `json:"in"`
+		End  int64   `json:"end"`
+		Out  int64   `json:"out"`
+		Kind string  `json:"kind"`
+		Len  int     `json:"len"`
+		Off  *int    `json:"off,omitempty"`  // copies
+		Src  *int64  `json:"src,omitempty"`  // resolved copies
+		Meta *int    `json:"meta,omitempty"` // meta tag as eazy.Dump prints it
+		Data *string `json:"data,omitempty"` // valid UTF-8 data as is
+
+		// Data64 is data which is not valid UTF-8, json would replace invalid bytes.
+		// It's standard base64 encoded.
+		Data64 []byte `json:"data64,omitempty"`
+	}
+)
+
+var errDumpDone = errors.New("dump done")
+
+func (a *app) dump(args []string) int {
+	fs := flag.NewFlagSet("eazy dump", flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), `usage: eazy dump [flags] [file ...]
+
+Print compressed stream elements.
+Element kinds are %s.
+
+`, strings.Join(elemNames[:], ", "))
+		fs.PrintDefaults()
+	}
+
+	format := fs.String("format", "text", "output format: text, csv or json (one object per line, data not valid UTF-8 is base64 data64 field)")
+	kinds := fs.String("kinds", "", "comma separated element kinds to print, all by default")
+	data := fs.Bool("data", false, "print copy source offsets and decoded bytes")
+	header := fs.Bool("header", true, "print csv header row")
+	from := fs.Int64("from", 0, "print elements decoding at and after the decompressed offset")
+	to := fs.Int64("to", 0, "print elements decoding before the decompressed offset, 0 means to the end")
+	inFrom := fs.Int64("in-from", 0, "print elements at and after the compressed offset")
+	inTo := fs.Int64("in-to", 0, "print elements before the compressed offset, 0 means to the end")
+	a.limitFlag(fs)
+
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	d := &dumper{
+		w:      a.stdout,
+		format: *format,
+		data:   *data,
+		limit:  a.opts.BlockSizeLimit,
+		from:   *from,
+		to:     *to,
+		inFrom: *inFrom,
+		inTo:   *inTo,
+	}
+
+	err = d.setKinds(*kinds)
+	if err != nil {
+		return a.fail("", err)
+	}
+
+	switch d.format {
+	case "text", "json":
+	case "csv":
+		d.csv = csv.NewWriter(a.stdout)
+
+		if *header {
+			_ = d.csv.Write([]string{"in", "end", "out", "kind", "len", "off", "src", "data"})
+		}
+	default:
+		return a.fail("", fmt.Errorf("unknown format: %q", d.format))
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	code := 0
+
+	for _, name := range files {
+		err = a.dumpFile(d, name)
+		if err != nil {
+			code = a.fail(name, err)
+		}
+	}
+
+	if d.csv != nil {
+		d.csv.Flush()
+
+		if err = d.csv.Error(); err != nil {
+			code = a.fail("", err)
+		}
+	}
+
+	return code
+}
+
+func (a *app) dumpFile(d *dumper, name string) (err error) {
+	r := a.stdin
+
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+
+		defer closeIt(f, &err, "close")
+
+		r = f
+	}
+
+	err = elements(r, d.limit, d.data, d.element)
+	if errors.Is(err, errDumpDone) {
+		return nil
+	}
+
+	return err
+}
+
+func (d *dumper) setKinds(s string) error {
+	if s == "" {
+		for k := range d.kinds {
+			d.kinds[k] = true
+		}
+
+		return nil
+	}
+
+outer:
+	for _, name := range strings.Split(s, ",") {
+		for k, n := range elemNames {
+			if name == n {
+				d.kinds[k] = true
+				continue outer
+			}
+		}
+
+		return fmt.Errorf("unknown element kind: %q", name)
+	}
+
+	return nil
+}
+
+func (d *dumper) element(e element) error {
+	if d.inTo != 0 && e.In >= d.inTo || d.to != 0 && e.Out >= d.to {
+		return errDumpDone
+	}
+
+	k := kind(e.Tag, e.Len, e.Off)
+
+	if !d.kinds[k] || e.End <= d.inFrom {
+		return nil
+	}
+
+	if out := e.Out + outLen(e); out <= d.from && e.Out < d.from {
+		return nil
+	}
+
+	switch d.format {
+	case "csv":
+		return d.writeCSV(e, k)
+	case "json":
+		return d.writeJSON(e, k)
+	default:
+		return d.writeText(e)
+	}
+}
+
+// writeText prints the element the way eazy.Dump does.
+func (d *dumper) writeText(e element) (err error) {
+	b := fmt.Appendf(d.b[:0], "%8x  %8x  ", e.In, e.Out)
+
+	switch e.Tag {
+	case 'l':
+		b = fmt.Appendf(b, "lit  %4x        %q\n", e.Len, e.Data)
+	case 'c':
+		b = fmt.Appendf(b, "copy %4x  off %4x", e.Len, e.Off)
+
+		if d.data && e.Off != 0 {
+			b = fmt.Appendf(b, "  from %x", e.Src)
+		}
+
+		if d.data {
+			b = fmt.Appendf(b, "  %q", e.Data)
+		}
+
+		b = append(b, '\n')
+	case 'm':
+		b = fmt.Appendf(b, "meta %2x %x  %-8q  % [3]x\n", e.Off>>3, e.Len, e.Data)
+	case 'p':
+		b = fmt.Appendf(b, "pad  %4x\n", e.Len)
+	}
+
+	d.b = b
+
+	_, err = d.w.Write(b)
+
+	return err
+}
+
+func (d *dumper) writeCSV(e element, k int) error {
+	off, src := "", ""
+
+	switch e.Tag {
+	case 'c':
+		off = strconv.Itoa(e.Off)
+
+		if d.data {
+			src = strconv.FormatInt(e.Src, 10)
+		}
+	case 'm':
+		off = strconv.Itoa(e.Off >> 3)
+	}
+
+	return d.csv.Write([]string{
+		strconv.FormatInt(e.In, 10),
+		strconv.FormatInt(e.End, 10),
+		strconv.FormatInt(e.Out, 10),
+		elemNames[k],
+		strconv.Itoa(e.Len),
+		off,
+		src,
+		string(e.Data),
+	})
+}
+
+func (d *dumper) writeJSON(e element, k int) error {
+	x := dumpJSON{
+		In:   e.In,
+		End:  e.End,
+		Out:  e.Out,
+		Kind: elemNames[k],
+		Len:  e.Len,
+	}
+
+	off := e.Off
+
+	switch e.Tag {
+	case 'c':
+		x.Off = &off
+
+		if d.data {
+			x.Src = &e.Src
+		}
+	case 'm':
+		off >>= 3
+		x.Meta = &off
+	}
+
+	switch {
+	case e.Data == nil:
+	case utf8.Valid(e.Data):
+		s := string(e.Data)
+		x.Data = &s
+	default:
+		x.Data64 = e.Data
+	}
+
+	b, err := json.Marshal(x)
+	if err != nil {
+		return err
+	}
+
+	d.b = append(append(d.b[:0], b...), '\n')
+
+	_, err = d.w.Write(d.b)
+
+	return err
+}
+
+// elements calls f for each element of the compressed stream from r.
+// Dumper doesn't pass literal and meta data to Debug, so the input is kept here
+// to read it while the callback is running.
+// Copies are resolved to their data if resolve is set,
+// the window is limited by limit which is as eazy.Options.BlockSizeLimit.
+func elements(r io.Reader, limit int, resolve bool, f func(e element) error) error {
+	buf := make([]byte, 64*eazy.KiB)
+	var base int64 // buf[0] input offset
+	var keep int
+	var ferr error
+
+	d := eazy.NewDumper(nil)
+	d.GlobalOffset = -1
+	d.KeepWindow = resolve
+
+	switch {
+	case limit < 0:
+		d.BlockSizeLimit = 0 // no limit
+	case limit > 0:
+		d.BlockSizeLimit = limit
+	}
+
+	d.Debug = func(ioff, iend, ooff int64, tag byte, l, off int) {
+		if ferr != nil || tag == 'e' {
+			return
+		}
+
+		e := element{In: ioff, End: iend, Out: ooff, Tag: tag, Len: l, Off: off}
+
+		if tag == 'l' || tag == 'm' {
+			e.Data = buf[iend-base : iend-base+int64(l)]
+			e.End += int64(l)
+		}
+
+		if tag == 'c' && resolve {
+			e.Src, e.Data = d.Copied()
+		}
+
+		ferr = f(e)
+	}
+
+	for {
+		n, err := r.Read(buf[keep:])
+		n += keep
+
+		m, werr := d.Write(buf[:n])
+		keep = copy(buf, buf[m:n])
+		base += int64(m)
+
+		if ferr != nil {
+			return ferr
+		}
+
+		if werr != nil && !errors.Is(werr, eazy.ErrShortBuffer) {
+			return werr
+		}
+
+		if keep == len(buf) {
+			buf = append(buf, make([]byte, len(buf))...)
+		}
+
+		if errors.Is(err, io.EOF) && keep != 0 {
+			return io.ErrUnexpectedEOF
+		}
+		if errors.Is(err, io.EOF) {
+			return d.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// outLen is the number of decompressed bytes the element produces.
+func outLen(e element) int64 {
+	if e.Tag == 'l' || e.Tag == 'c' {
+		return int64(e.Len)
+	}
+
+	return 0
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump(t *testing.T) {
+	enc := encode(t, "first line, first line\naaaaaaaaaaaaaaaaaaaaaaaaaaaa\n")
+
+	a, stdout, stderr := testApp(enc)
+	code := a.run([]string{"dump", "-data"})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+	assert.Equal(t, `       0         0  meta  0 4  "eazy"    65 61 7a 79
+       6         0  meta  2 1  "\n"      0a
+       9         0  lit     c        "first line, "
+      16         c  copy    a  off    c  from 0  "first line"
+      18        16  lit     2        "\na"
+      1b        18  copy   1b  off    1  from 17  "aaaaaaaaaaaaaaaaaaaaaaaaaaa"
+      1e        33  lit     1        "\n"
+`, stdout.String())
+
+	a, stdout, stderr = testApp(enc)
+	code = a.run([]string{"dump", "-format", "json", "-kinds", "copy,runlen", "-data"})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+
+	var res []dumpJSON
+
+	for _, l := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		var x dumpJSON
+
+		err := json.Unmarshal([]byte(l), &x)
+		require.NoError(t, err, "line: %s", l)
+
+		res = append(res, x)
+	}
+
+	if assert.Len(t, res, 2) {
+		assert.Equal(t, "copy", res[0].Kind)
+		assert.Equal(t, int64(12), res[0].Out)
+		assert.Equal(t, int64(0), *res[0].Src)
+		assert.Equal(t, "first line", *res[0].Data)
+
+		assert.Equal(t, "runlen", res[1].Kind)
+		assert.Equal(t, 1, *res[1].Off)
+		assert.Equal(t, strings.Repeat("a", 27), *res[1].Data)
+	}
+
+	a, stdout, stderr = testApp(enc)
+	code = a.run([]string{"dump", "-format", "csv", "-from", "20", "-to", "30"})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+
+	rows, err := csv.NewReader(stdout).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"in", "end", "out", "kind", "len", "off", "src", "data"},
+		{"22", "24", "12", "copy", "10", "12", "", ""},
+		{"24", "27", "22", "literal", "2", "", "", "\na"},
+		{"27", "30", "24", "runlen", "27", "1", "", ""},
+	}, rows)
+
+	a, stdout, stderr = testApp(enc)
+	code = a.run([]string{"dump", "-format", "csv", "-header=false", "-in-from", "6", "-in-to", "9"})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+	assert.Equal(t, "6,9,0,meta,1,2,,\"\n\"\n", stdout.String())
+
+	bin := "\xff\xfe\x00\x80 binary"
+
+	a, stdout, stderr = testApp(encode(t, bin))
+	code = a.run([]string{"dump", "-format", "json", "-kinds", "literal"})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+
+	var x dumpJSON
+
+	err = json.Unmarshal(stdout.Bytes(), &x)
+	require.NoError(t, err)
+	assert.Nil(t, x.Data)
+	assert.Equal(t, []byte(bin), x.Data64)
+	assert.Contains(t, stdout.String(), `"data64":"`+base64.StdEncoding.EncodeToString([]byte(bin))+`"`)
+
+	a, _, stderr = testApp(enc)
+	code = a.run([]string{"dump", "-kinds", "bad"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "unknown element kind")
+
+	a, _, stderr = testApp(enc[:len(enc)-1])
+	code = a.run([]string{"dump"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "unexpected EOF")
+}
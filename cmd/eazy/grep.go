@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+
+	"tlog.app/go/eazy"
+)
+
+type grepFile struct {
+	out  chan []byte // output chunks in order
+	stop chan struct{}
+	b    []byte // current chunk
+
+	found bool
+	err   error
+}
+
+// grepChunk is the output chunk size of a file searched in parallel with others.
+// Output is sent to the printer in chunks, so at most a few chunks per file are buffered.
+const grepChunk = 64 * eazy.KiB
+
+var errGrepStopped = errors.New("stopped")
+
+func (a *app) grep(args []string) int {
+	fs := flag.NewFlagSet("eazy grep", flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), `usage: eazy grep [flags] pattern [file ...]
+
+Matching lines are printed as [file:]in:out:line,
+where in is the compressed offset of the stream header decoding can be started from
+and out is the decompressed offset of the line.
+Files with %s index are searched by segments in parallel.
+
+`, eazy.IndexExt)
+		fs.PrintDefaults()
+	}
+
+	fixed := fs.Bool("F", false, "pattern is a fixed string")
+	icase := fs.Bool("i", false, "ignore case")
+	noName := fs.Bool("h", false, "don't print file names")
+	workers := fs.Int("j", runtime.GOMAXPROCS(0), "files or segments to search in parallel")
+	from := fs.Int64("from", 0, "decompressed offset to search from")
+	to := fs.Int64("to", 0, "decompressed offset to search to, 0 means to the end")
+	a.limitFlag(fs)
+
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	m, err := matcher(fs.Arg(0), *fixed, *icase)
+	if err != nil {
+		a.fail("", err)
+		return 2
+	}
+
+	files := fs.Args()[1:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	opts := eazy.GrepOptions{
+		Options: a.opts,
+		From:    *from,
+		To:      *to,
+		Workers: *workers,
+	}
+
+	if len(files) == 1 {
+		found, err := a.grepFile(files[0], "", m, opts, a.stdout)
+
+		switch {
+		case err != nil:
+			a.fail(files[0], err)
+			return 2
+		case found:
+			return 0
+		default:
+			return 1
+		}
+	}
+
+	opts.Workers = 1 // files are searched in parallel instead
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	res := make([]*grepFile, len(files))
+
+	for i := range res {
+		res[i] = &grepFile{out: make(chan []byte, 4), stop: stop}
+	}
+
+	// files are started in order, so the one being printed is always running
+	go func() {
+		sem := make(chan struct{}, *workers)
+
+		for i, name := range files {
+			select {
+			case sem <- struct{}{}:
+			case <-stop:
+				return
+			}
+
+			go func(name string, r *grepFile) {
+				defer func() { <-sem }()
+				defer close(r.out)
+
+				prefix := ""
+				if !*noName {
+					prefix = name + ":"
+				}
+
+				r.found, r.err = a.grepFile(name, prefix, m, opts, r)
+				if r.err == nil {
+					r.err = r.flush()
+				}
+			}(name, res[i])
+		}
+	}()
+
+	code := 1
+
+	for i, r := range res {
+		for p := range r.out {
+			_, err = a.stdout.Write(p)
+			if err != nil {
+				a.fail("", err)
+				return 2
+			}
+		}
+
+		if r.err != nil {
+			a.fail(files[i], r.err)
+			code = 2
+		}
+
+		if r.found && code == 1 {
+			code = 0
+		}
+	}
+
+	return code
+}
+
+func (a *app) grepFile(name, prefix string, m eazy.Matcher, opts eazy.GrepOptions, w io.Writer) (found bool, err error) {
+	var b []byte
+
+	emit := func(m eazy.Match) error {
+		found = true
+
+		b = append(b[:0], prefix...)
+		b = strconv.AppendInt(b, m.In, 10)
+		b = append(b, ':')
+		b = strconv.AppendInt(b, m.Out, 10)
+		b = append(b, ':')
+		b = append(b, m.Line...)
+		b = append(b, '\n')
+
+		_, err := w.Write(b)
+
+		return err
+	}
+
+	err = a.grepInput(name, m, opts, emit)
+
+	return found, err
+}
+
+// grepInput searches stdin, an indexed file or a plain file.
+func (a *app) grepInput(name string, m eazy.Matcher, opts eazy.GrepOptions, emit func(eazy.Match) error) (err error) {
+	if name == "-" {
+		return eazy.Grep(a.stdin, m, opts, emit)
+	}
+
+	if _, err = os.Stat(name + eazy.IndexExt); err == nil {
+		return a.grepIndexed(name, m, opts, emit)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer closeIt(f, &err, "close")
+
+	return eazy.Grep(f, m, opts, emit)
+}
+
+// Write buffers grep output into chunks sent to the printer.
+func (r *grepFile) Write(p []byte) (int, error) {
+	r.b = append(r.b, p...)
+
+	if len(r.b) < grepChunk {
+		return len(p), nil
+	}
+
+	return len(p), r.flush()
+}
+
+func (r *grepFile) flush() error {
+	if len(r.b) == 0 {
+		return nil
+	}
+
+	select {
+	case r.out <- r.b:
+	case <-r.stop:
+		return errGrepStopped
+	}
+
+	r.b = nil
+
+	return nil
+}
+
+func (a *app) grepIndexed(name string, m eazy.Matcher, opts eazy.GrepOptions, emit func(eazy.Match) error) (err error) {
+	f, err := eazy.OpenIndexed(name)
+	if err != nil {
+		return err
+	}
+
+	defer closeIt(f, &err, "close")
+
+	return eazy.GrepIndexed(f, m, opts, emit)
+}
+
+func matcher(pattern string, fixed, icase bool) (eazy.Matcher, error) {
+	if fixed && !icase {
+		return eazy.Fixed(pattern), nil
+	}
+
+	if fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	if icase {
+		pattern = "(?i)" + pattern
+	}
+
+	return regexp.Compile(pattern)
+}
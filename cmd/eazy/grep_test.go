@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"tlog.app/go/eazy"
+)
+
+func TestGrep(t *testing.T) {
+	dir := t.TempDir()
+
+	var data bytes.Buffer
+
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&data, "record %d level=%s\n", i, []string{"info", "warn", "ERROR"}[i%3])
+	}
+
+	plain := filepath.Join(dir, "a.ez")
+	indexed := filepath.Join(dir, "b.ez")
+
+	err := os.WriteFile(plain, encode(t, data.String()), 0o644)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+
+	_, err = eazy.CompressParallel(&b, bytes.NewReader(data.Bytes()), eazy.ParallelOptions{
+		Options:     eazy.DefaultOptions(),
+		SegmentSize: 1000,
+	})
+	require.NoError(t, err)
+
+	err = os.WriteFile(indexed, b.Bytes(), 0o644)
+	require.NoError(t, err)
+
+	x, err := eazy.BuildIndex(bytes.NewReader(b.Bytes()))
+	require.NoError(t, err)
+
+	err = eazy.WriteIndexFile(indexed+eazy.IndexExt, x)
+	require.NoError(t, err)
+
+	a, stdout, stderr := testApp(nil)
+	code := a.run([]string{"grep", `record 1\d*0 level=warn`, plain})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	require.NotEmpty(t, lines)
+
+	for _, l := range lines {
+		var in, out int
+
+		_, err = fmt.Sscanf(l, "%d:%d:", &in, &out)
+		require.NoError(t, err, "line %q", l)
+
+		assert.Zero(t, in)
+		assert.True(t, strings.HasPrefix(data.String()[out:], l[strings.Index(l, "record"):]+"\n"), "line %q", l)
+	}
+
+	a, stdout2, _ := testApp(nil)
+	code = a.run([]string{"grep", "-i", "-j", "2", `record 1\d*0 LEVEL=warn`, plain, indexed})
+	assert.Equal(t, 0, code)
+
+	out := strings.Split(strings.TrimSuffix(stdout2.String(), "\n"), "\n")
+	require.Len(t, out, 2*len(lines))
+
+	for i, l := range lines {
+		assert.Equal(t, plain+":"+l, out[i])
+		assert.True(t, strings.HasPrefix(out[len(lines)+i], indexed+":"), "line %q", out[len(lines)+i])
+		assert.True(t, strings.HasSuffix(out[len(lines)+i], l[strings.Index(l, ":")+1:]), "line %q", out[len(lines)+i])
+	}
+
+	a, stdout, _ = testApp(nil)
+	code = a.run([]string{"grep", "-F", "-h", "-from", "1000", "record 1998 level=info", plain, indexed})
+	assert.Equal(t, 0, code)
+	assert.Equal(t, 2, strings.Count(stdout.String(), "\n"))
+
+	a, stdout, _ = testApp(nil)
+	code = a.run([]string{"grep", "-F", "no such record", plain})
+	assert.Equal(t, 1, code)
+	assert.Zero(t, stdout.Len())
+
+	a, _, stderr = testApp(nil)
+	code = a.run([]string{"grep", "x", filepath.Join(dir, "missing.ez")})
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "missing.ez")
+}
+
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+
+	return w.Buffer.Write(p)
+}
+
+func TestGrepStream(t *testing.T) {
+	dir := t.TempDir()
+
+	var data bytes.Buffer
+
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&data, "record %d\n", i)
+	}
+
+	names := []string{filepath.Join(dir, "a.ez"), filepath.Join(dir, "b.ez"), filepath.Join(dir, "c.ez")}
+
+	for _, name := range names {
+		err := os.WriteFile(name, encode(t, data.String()), 0o644)
+		require.NoError(t, err)
+	}
+
+	// single file is written as it's searched
+
+	var w writeCounter
+
+	a, _, stderr := testApp(nil)
+	a.stdout = &w
+
+	code := a.run([]string{"grep", "-F", "record", names[0]})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+	assert.Equal(t, 20000, w.writes)
+
+	// many files are printed in order by chunks
+
+	w = writeCounter{}
+
+	a, _, stderr = testApp(nil)
+	a.stdout = &w
+
+	code = a.run(append([]string{"grep", "-F", "-j", "2", "record"}, names...))
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+	assert.Greater(t, w.writes, len(names))
+	assert.Less(t, w.writes, 20000)
+
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+	require.Len(t, lines, len(names)*20000)
+
+	for i, l := range lines {
+		name, rec := names[i/20000], fmt.Sprintf("record %d", i%20000)
+
+		if !assert.True(t, strings.HasPrefix(l, name+":") && strings.HasSuffix(l, ":"+rec), "line %d: %q", i, l) {
+			break
+		}
+	}
+}
@@ -0,0 +1,451 @@
+// Command eazy compresses and decompresses files in eazy format.
+//
+// It follows gzip conventions:
+//
+//	eazy file          compress file into file.ez and remove file
+//	eazy -d file.ez    decompress file.ez into file and remove file.ez
+//	eazy -k file       keep the original file
+//	eazy -c file       write to stdout and keep the original file
+//	eazy < in > out    compress stdin to stdout
+//	eazy -dc file.ez   decompress to stdout
+//
+// Short bool flags can be combined as in -dc.
+//
+// Subcommands are selected by the first argument:
+//
+//	eazy cat [-f] [file ...]          decompress files to stdout, follow a growing file
+//	eazy dump [flags] [file ...]      print stream elements as text, csv or json
+//	eazy grep [flags] pattern [file]  search compressed files
+//	eazy stat [file ...]              print stream composition
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"tlog.app/go/eazy"
+)
+
+type (
+	app struct {
+		ctx context.Context
+
+		stdin  io.Reader
+		stdout io.Writer
+		stderr io.Writer
+
+		stdoutFlag bool
+		decompress bool
+		keep       bool
+		force      bool
+		verbose    bool
+		suffix     string
+
+		opts eazy.Options
+	}
+
+	// size is a flag value accepting K, M and G binary suffixes.
+	size int
+
+	countWriter struct {
+		io.Writer
+		n int64
+	}
+
+	countReader struct {
+		io.Reader
+		n int64
+	}
+)
+
+var errSkipped = errors.New("skipped")
+
+// commands are subcommands selected by the first argument.
+var commands = map[string]func(a *app, args []string) int{
+	"cat":  (*app).cat,
+	"dump": (*app).dump,
+	"grep": (*app).grep,
+	"stat": (*app).stat,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	a := &app{
+		ctx:    ctx,
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+
+	code := a.run(os.Args[1:])
+
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command and returns exit code.
+func (a *app) run(args []string) int {
+	if len(args) != 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			a.opts = defaultOptions()
+
+			return cmd(a, args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("eazy", flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eazy [flags] [file ...]\n\n")
+		fs.PrintDefaults()
+	}
+
+	a.opts = defaultOptions()
+
+	block := size(eazy.DefaultBlockSize)
+	hash := size(eazy.DefaultHashTableSize)
+
+	fs.BoolVar(&a.stdoutFlag, "c", false, "write to standard output, keep original files")
+	fs.BoolVar(&a.decompress, "d", false, "decompress")
+	fs.BoolVar(&a.keep, "k", false, "keep original files")
+	fs.BoolVar(&a.force, "f", false, "overwrite existing files and write compressed data to a terminal")
+	fs.BoolVar(&a.verbose, "v", false, "print compression ratio of each file")
+	fs.StringVar(&a.suffix, "S", ".ez", "compressed file suffix")
+	fs.Var(&block, "b", "block size, power of two (K, M and G suffixes are accepted)")
+	fs.Var(&hash, "hash", "hash table size, power of two")
+	a.limitFlag(fs)
+
+	err := fs.Parse(splitShort(args, "cdkfv"))
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	if a.suffix == "" {
+		return a.fail("", errors.New("empty suffix"))
+	}
+
+	a.opts.BlockSize = int(block)
+	a.opts.HashTableSize = int(hash)
+
+	// the limit is for reading, blocks of any size can be written
+	if !a.decompress && a.opts.BlockSizeLimit > 0 && a.opts.BlockSizeLimit < a.opts.BlockSize {
+		a.opts.BlockSizeLimit = a.opts.BlockSize
+	}
+
+	_, err = eazy.NewWriterOptions(nil, a.opts)
+	if err != nil {
+		return a.fail("", err)
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	code := 0
+
+	for _, name := range files {
+		err = a.file(name)
+		if errors.Is(err, errSkipped) {
+			if code == 0 {
+				code = 2
+			}
+
+			continue
+		}
+		if err != nil {
+			code = a.fail(name, err)
+		}
+	}
+
+	return code
+}
+
+func (a *app) file(name string) (err error) {
+	if name == "-" {
+		return a.stream(a.stdout, a.stdin)
+	}
+
+	oname, err := a.outName(name)
+	if err != nil {
+		return err
+	}
+
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer closeIt(in, &err, "close input")
+
+	inf, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if inf.IsDir() {
+		fmt.Fprintf(a.stderr, "eazy: %s: is a directory -- ignored\n", name)
+		return errSkipped
+	}
+
+	if !inf.Mode().IsRegular() && !a.force && !a.stdoutFlag {
+		fmt.Fprintf(a.stderr, "eazy: %s: not a regular file -- ignored\n", name)
+		return errSkipped
+	}
+
+	if a.stdoutFlag {
+		return a.stream(a.stdout, in)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if a.force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	out, err := os.OpenFile(oname, flags, inf.Mode().Perm())
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists, use -f to overwrite", oname)
+	}
+	if err != nil {
+		return err
+	}
+
+	cw := &countWriter{Writer: out}
+
+	err = a.stream(cw, in)
+
+	if e := out.Close(); err == nil && e != nil {
+		err = fmt.Errorf("close output: %w", e)
+	}
+
+	if err != nil {
+		_ = os.Remove(oname)
+		return err
+	}
+
+	_ = os.Chtimes(oname, inf.ModTime(), inf.ModTime())
+
+	if a.verbose {
+		a.report(name, oname, inf.Size(), cw.n)
+	}
+
+	if a.keep {
+		return nil
+	}
+
+	return os.Remove(name)
+}
+
+func (a *app) outName(name string) (string, error) {
+	if a.stdoutFlag {
+		return "", nil
+	}
+
+	has := strings.HasSuffix(name, a.suffix)
+
+	switch {
+	case a.decompress && (!has || len(name) == len(a.suffix)):
+		return "", fmt.Errorf("unknown suffix -- ignored")
+	case a.decompress:
+		return strings.TrimSuffix(name, a.suffix), nil
+	case has && !a.force:
+		return "", fmt.Errorf("already has %s suffix -- unchanged", a.suffix)
+	default:
+		return name + a.suffix, nil
+	}
+}
+
+func (a *app) stream(w io.Writer, r io.Reader) error {
+	if a.decompress {
+		if isTerminal(r) && !a.force {
+			return errors.New("compressed data not read from a terminal, use -f to force decompression")
+		}
+
+		return a.decompressStream(w, r)
+	}
+
+	if isTerminal(w) && !a.force {
+		return errors.New("compressed data not written to a terminal, use -f to force compression")
+	}
+
+	return a.compressStream(w, r)
+}
+
+func (a *app) compressStream(w io.Writer, r io.Reader) error {
+	ew, err := eazy.NewWriterOptions(w, a.opts)
+	if err != nil {
+		return err
+	}
+
+	// empty input still results in a recognizable stream
+	err = ew.WriteHeader()
+	if err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	_, err = ew.ReadFrom(r)
+	if err != nil {
+		return err
+	}
+
+	return ew.Flush()
+}
+
+func (a *app) decompressStream(w io.Writer, r io.Reader) error {
+	er, err := eazy.NewReaderOptions(r, a.opts)
+	if err != nil {
+		return err
+	}
+
+	defer er.Release()
+
+	for {
+		_, err = er.WriteTo(w)
+		if !errors.Is(err, eazy.ErrBreak) {
+			return err
+		}
+	}
+}
+
+func (a *app) report(name, oname string, in, out int64) {
+	comp, orig := out, in
+	if a.decompress {
+		comp, orig = in, out
+	}
+
+	saved := 0.
+	if orig != 0 {
+		saved = 100 * float64(orig-comp) / float64(orig)
+	}
+
+	fmt.Fprintf(a.stderr, "%s:\t%5.1f%% -- replaced with %s\n", name, saved, oname)
+}
+
+func (a *app) fail(name string, err error) int {
+	if name != "" {
+		fmt.Fprintf(a.stderr, "eazy: %s: %v\n", name, err)
+	} else {
+		fmt.Fprintf(a.stderr, "eazy: %v\n", err)
+	}
+
+	return 1
+}
+
+// defaultOptions returns options for local files.
+// Block size is limited as any file can be fed to the command,
+// -block-limit flag raises it.
+func defaultOptions() eazy.Options {
+	opts := eazy.DefaultOptions()
+	opts.RequireMagic = true
+
+	return opts
+}
+
+// limitFlag adds the flag setting the input block size limit.
+func (a *app) limitFlag(fs *flag.FlagSet) {
+	fs.Var((*size)(&a.opts.BlockSizeLimit), "block-limit", "max block size of compressed input, decoding window takes that much memory, -1 means no limit")
+}
+
+// splitShort expands combined short bool flags like -dc into -d -c.
+func splitShort(args []string, bools string) []string {
+	res := make([]string, 0, len(args))
+
+	for i, a := range args {
+		if a == "--" {
+			return append(res, args[i:]...)
+		}
+
+		if len(a) < 3 || a[0] != '-' || a[1] == '-' || strings.Trim(a[1:], bools) != "" {
+			res = append(res, a)
+			continue
+		}
+
+		for _, c := range a[1:] {
+			res = append(res, "-"+string(c))
+		}
+	}
+
+	return res
+}
+
+func isTerminal(x interface{}) bool {
+	f, ok := x.(*os.File)
+	if !ok {
+		return false
+	}
+
+	inf, err := f.Stat()
+
+	return err == nil && inf.Mode()&os.ModeCharDevice != 0
+}
+
+func closeIt(c io.Closer, errp *error, msg string) {
+	err := c.Close()
+	if *errp == nil && err != nil {
+		*errp = fmt.Errorf("%s: %w", msg, err)
+	}
+}
+
+func (s *size) Set(v string) error {
+	mul := 1
+
+	switch {
+	case strings.HasSuffix(v, "K"):
+		mul = eazy.KiB
+	case strings.HasSuffix(v, "M"):
+		mul = eazy.MiB
+	case strings.HasSuffix(v, "G"):
+		mul = eazy.GiB
+	}
+
+	if mul != 1 {
+		v = v[:len(v)-1]
+	}
+
+	x, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+
+	*s = size(x * mul)
+
+	return nil
+}
+
+func (s size) String() string {
+	switch {
+	case s != 0 && s%eazy.GiB == 0:
+		return fmt.Sprintf("%dG", s/eazy.GiB)
+	case s != 0 && s%eazy.MiB == 0:
+		return fmt.Sprintf("%dM", s/eazy.MiB)
+	case s != 0 && s%eazy.KiB == 0:
+		return fmt.Sprintf("%dK", s/eazy.KiB)
+	default:
+		return strconv.Itoa(int(s))
+	}
+}
+
+func (w *countWriter) Write(p []byte) (n int, err error) {
+	n, err = w.Writer.Write(p)
+	w.n += int64(n)
+
+	return n, err
+}
+
+func (r *countReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.n += int64(n)
+
+	return n, err
+}
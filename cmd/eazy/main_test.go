@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testApp(stdin []byte) (*app, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+
+	return &app{
+		ctx:    context.Background(),
+		stdin:  bytes.NewReader(stdin),
+		stdout: &stdout,
+		stderr: &stderr,
+	}, &stdout, &stderr
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "log.txt")
+	data := []byte(strings.Repeat("some log message 0123456789\n", 1000))
+
+	err := os.WriteFile(name, data, 0o640)
+	require.NoError(t, err)
+
+	a, _, stderr := testApp(nil)
+	code := a.run([]string{"-v", "-b", "64K", name})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+	assert.Contains(t, stderr.String(), "replaced with "+name+".ez")
+
+	assert.NoFileExists(t, name)
+
+	enc, err := os.ReadFile(name + ".ez")
+	require.NoError(t, err)
+	assert.Less(t, len(enc), len(data)/10)
+
+	a, _, stderr = testApp(nil)
+	code = a.run([]string{name + ".ez"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "already has .ez suffix")
+
+	a, stdout, _ := testApp(nil)
+	code = a.run([]string{"-dc", name + ".ez"})
+	assert.Equal(t, 0, code)
+	assert.Equal(t, data, stdout.Bytes())
+	assert.FileExists(t, name+".ez")
+
+	a, _, stderr = testApp(nil)
+	code = a.run([]string{"-dk", name + ".ez"})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+	assert.FileExists(t, name+".ez")
+
+	dec, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, data, dec)
+
+	a, _, stderr = testApp(nil)
+	code = a.run([]string{"-d", name + ".ez"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "already exists")
+	assert.FileExists(t, name+".ez")
+
+	a, _, _ = testApp(nil)
+	code = a.run([]string{"-d", "-f", name + ".ez"})
+	assert.Equal(t, 0, code)
+	assert.NoFileExists(t, name+".ez")
+
+	a, _, stderr = testApp(nil)
+	code = a.run([]string{"-d", name})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "unknown suffix")
+
+	a, _, stderr = testApp(nil)
+	code = a.run([]string{dir})
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "is a directory")
+}
+
+func TestPipe(t *testing.T) {
+	data := []byte(strings.Repeat("some log message 0123456789\n", 100))
+
+	a, stdout, _ := testApp(data)
+	code := a.run(nil)
+	assert.Equal(t, 0, code)
+
+	enc := stdout.Bytes()
+
+	a, stdout, _ = testApp(enc)
+	code = a.run([]string{"-d", "-"})
+	assert.Equal(t, 0, code)
+	assert.Equal(t, data, stdout.Bytes())
+
+	a, stdout, _ = testApp(nil)
+	code = a.run(nil)
+	assert.Equal(t, 0, code)
+	assert.NotZero(t, stdout.Len(), "header")
+
+	a, stdout, _ = testApp(stdout.Bytes())
+	code = a.run([]string{"-d"})
+	assert.Equal(t, 0, code)
+	assert.Zero(t, stdout.Len())
+
+	a, _, stderr := testApp(data)
+	code = a.run([]string{"-d"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "no magic")
+
+	a, _, stderr = testApp(data)
+	code = a.run([]string{"-b", "1000"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "block size")
+}
+
+func TestBlockLimit(t *testing.T) {
+	data := []byte(strings.Repeat("some log message 0123456789\n", 100))
+
+	a, stdout, stderr := testApp(data)
+	code := a.run([]string{"-b", "32M"}) // the limit is not for writing
+	require.Equal(t, 0, code, "stderr: %s", stderr)
+
+	enc := stdout.Bytes()
+
+	for _, args := range [][]string{{"-d"}, {"cat"}, {"dump", "-data"}, {"grep", "log"}} {
+		a, _, stderr = testApp(enc)
+		code = a.run(args)
+		assert.NotZero(t, code, "args %q", args) // grep exits with 2 on errors
+		assert.Contains(t, stderr.String(), "block size is more than the limit", "args %q", args)
+
+		a, stdout, stderr = testApp(enc)
+		code = a.run(append(args[:1:1], append([]string{"-block-limit", "32M"}, args[1:]...)...))
+		assert.Equal(t, 0, code, "args %q, stderr: %s", args, stderr)
+		assert.NotZero(t, stdout.Len(), "args %q", args)
+	}
+}
+
+func TestSplitShort(t *testing.T) {
+	assert.Equal(t,
+		[]string{"-d", "-c", "-k", "-b", "64K", "--", "-dc"},
+		splitShort([]string{"-dc", "-k", "-b", "64K", "--", "-dc"}, "cdkfv"))
+
+	assert.Equal(t, []string{"-hash", "16"}, splitShort([]string{"-hash", "16"}, "cdkfv"))
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+
+	"tlog.app/go/eazy"
+)
+
+type (
+	// stats is stream composition collected from Dumper.Debug callbacks.
+	stats struct {
+		In, Out int64
+
+		Elems [elemKinds]elemStat
+
+		Magic   int
+		Streams int
+		Breaks  int
+		Unknown int // unknown metas
+
+		Versions   map[int]int
+		BlockSizes map[int]int // by log2
+
+		// bits.Len buckets, lengths and offsets can be up to 1<<32 + some
+		Lens [65]int64 // literal and copy lengths by log2 buckets
+		Offs [65]int64 // copy offsets by log2 buckets
+	}
+
+	elemStat struct {
+		Count   int64
+		In, Out int64 // compressed and decompressed bytes
+	}
+)
+
+// element kinds
+const (
+	elemLiteral = iota
+	elemCopy
+	elemZero
+	elemRunlen
+	elemMeta
+	elemPadding
+
+	elemKinds
+)
+
+var elemNames = [elemKinds]string{"literal", "copy", "zero", "runlen", "meta", "padding"}
+
+func (a *app) stat(args []string) int {
+	fs := flag.NewFlagSet("eazy stat", flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: eazy stat [file ...]\n\nPrint compressed stream composition.\n\n")
+		fs.PrintDefaults()
+	}
+
+	hist := fs.Bool("hist", true, "print length and offset histograms")
+
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	code := 0
+
+	for i, name := range files {
+		if len(files) > 1 {
+			if i != 0 {
+				fmt.Fprintf(a.stdout, "\n")
+			}
+
+			fmt.Fprintf(a.stdout, "%s:\n", name)
+		}
+
+		err = a.statFile(name, *hist)
+		if err != nil {
+			code = a.fail(name, err)
+		}
+	}
+
+	return code
+}
+
+func (a *app) statFile(name string, hist bool) (err error) {
+	r := a.stdin
+
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+
+		defer closeIt(f, &err, "close")
+
+		r = f
+	}
+
+	var s stats
+
+	// the stats are printed even if the stream is broken
+	err = s.collect(r)
+	s.print(a.stdout, hist)
+
+	return err
+}
+
+// collect reads the stream from r.
+func (s *stats) collect(r io.Reader) error {
+	s.Versions = map[int]int{}
+	s.BlockSizes = map[int]int{}
+
+	return elements(r, 0, false, func(e element) error {
+		s.add(e)
+		return nil
+	})
+}
+
+func (s *stats) add(e element) {
+	k := kind(e.Tag, e.Len, e.Off)
+	in := e.End - e.In
+	out := outLen(e)
+
+	switch k {
+	case elemCopy, elemZero, elemRunlen:
+		s.Offs[bits.Len(uint(e.Off))]++
+	case elemMeta:
+		s.meta(e.Off, e.Data)
+	}
+
+	if e.Tag == 'l' || e.Tag == 'c' {
+		s.Lens[bits.Len(uint(e.Len))]++
+	}
+
+	el := &s.Elems[k]
+	el.Count++
+	el.In += in
+	el.Out += out
+
+	s.In += in
+	s.Out += out
+}
+
+// kind returns element kind of Dumper.Debug arguments.
+func kind(tag byte, l, off int) int {
+	switch {
+	case tag == 'm':
+		return elemMeta
+	case tag == 'p':
+		return elemPadding
+	case tag != 'c':
+		return elemLiteral
+	case off == 0:
+		return elemZero
+	case off < l:
+		return elemRunlen
+	default:
+		return elemCopy
+	}
+}
+
+func (s *stats) meta(meta int, data []byte) {
+	switch {
+	case meta == eazy.MetaMagic:
+		s.Magic++
+	case meta == eazy.MetaVer && len(data) == 1:
+		s.Versions[int(data[0])]++
+	case meta == eazy.MetaReset && len(data) == 1:
+		s.Streams++
+		s.BlockSizes[int(data[0])]++
+	case meta == eazy.MetaBreak:
+		s.Breaks++
+	default:
+		s.Unknown++
+	}
+}
+
+func (s *stats) print(w io.Writer, hist bool) {
+	ratio := 0.
+	if s.In != 0 {
+		ratio = float64(s.Out) / float64(s.In)
+	}
+
+	fmt.Fprintf(w, "input   %12d\noutput  %12d\nratio   %12.3f\n\n", s.In, s.Out, ratio)
+
+	fmt.Fprintf(w, "streams %d  magic %d  breaks %d", s.Streams, s.Magic, s.Breaks)
+
+	if s.Unknown != 0 {
+		fmt.Fprintf(w, "  unknown metas %d", s.Unknown)
+	}
+
+	fmt.Fprintf(w, "\n")
+
+	for _, v := range sortedKeys(s.Versions) {
+		fmt.Fprintf(w, "version %d: %d streams\n", v, s.Versions[v])
+	}
+
+	for _, bs := range sortedKeys(s.BlockSizes) {
+		fmt.Fprintf(w, "block size %s: %d streams\n", size(1<<bs), s.BlockSizes[bs])
+	}
+
+	fmt.Fprintf(w, "\n%-8s %10s %12s %6s %12s %6s\n", "element", "count", "in", "in%", "out", "out%")
+
+	for k, e := range s.Elems {
+		fmt.Fprintf(w, "%-8s %10d %12d %6.2f %12d %6.2f\n", elemNames[k], e.Count, e.In, percent(e.In, s.In), e.Out, percent(e.Out, s.Out))
+	}
+
+	if !hist {
+		return
+	}
+
+	printHist(w, "lengths", s.Lens[:])
+	printHist(w, "offsets", s.Offs[:])
+}
+
+// printHist prints log2 buckets histogram.
+// Bucket k counts values in [1<<(k-1), 1<<k).
+func printHist(w io.Writer, name string, h []int64) {
+	var tot int64
+
+	for _, c := range h {
+		tot += c
+	}
+
+	if tot == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n%s\n", name)
+
+	for k, c := range h {
+		if c == 0 {
+			continue
+		}
+
+		lo := 0
+		if k != 0 {
+			lo = 1 << (k - 1)
+		}
+
+		fmt.Fprintf(w, "  %7s - %-7s %10d %6.2f%%\n", size(lo), size(1<<k-1), c, percent(c, tot))
+	}
+}
+
+func percent(x, tot int64) float64 {
+	if tot == 0 {
+		return 0
+	}
+
+	return 100 * float64(x) / float64(tot)
+}
+
+func sortedKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Ints(keys)
+
+	return keys
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"tlog.app/go/eazy"
+)
+
+func TestStat(t *testing.T) {
+	var b bytes.Buffer
+
+	w := eazy.NewWriter(&b, 64*eazy.KiB, 1024)
+
+	for i := 0; i < 3; i++ {
+		if i != 0 {
+			w.Reset(&b)
+		}
+
+		_, err := w.Write([]byte(strings.Repeat("some message 0123456789\n", 500)))
+		require.NoError(t, err)
+
+		_, err = w.Write(make([]byte, 1000))
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte(strings.Repeat("a", 100)))
+		require.NoError(t, err)
+
+		_, err = w.Write([]byte("some message 0123456789\n"))
+		require.NoError(t, err)
+
+		err = w.WriteBreak()
+		require.NoError(t, err)
+
+		b.Write([]byte{0, 0})
+	}
+
+	enc := b.Bytes()
+
+	var s stats
+
+	err := s.collect(bytes.NewReader(enc))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(len(enc)), s.In)
+	assert.Equal(t, int64(3*(24*501+1100)), s.Out)
+	assert.Equal(t, 3, s.Streams)
+	assert.Equal(t, 3, s.Magic)
+	assert.Equal(t, 3, s.Breaks)
+	assert.Equal(t, map[int]int{16: 3}, s.BlockSizes)
+
+	assert.Equal(t, int64(3), s.Elems[elemZero].Count)
+	assert.Equal(t, int64(3000), s.Elems[elemZero].Out)
+	assert.NotZero(t, s.Elems[elemRunlen].Count)
+	assert.NotZero(t, s.Elems[elemCopy].Count)
+	assert.Equal(t, int64(6), s.Elems[elemPadding].In)
+
+	var in, out int64
+
+	for _, e := range s.Elems {
+		in += e.In
+		out += e.Out
+	}
+
+	assert.Equal(t, s.In, in)
+	assert.Equal(t, s.Out, out)
+
+	a, stdout, stderr := testApp(enc)
+	code := a.run([]string{"stat"})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+	assert.Contains(t, stdout.String(), "streams 3  magic 3  breaks 3")
+	assert.Contains(t, stdout.String(), "block size 64K: 3 streams")
+	assert.Contains(t, stdout.String(), "offsets")
+
+	a, _, stderr = testApp(enc[:len(enc)-100])
+	code = a.run([]string{"stat", "-hist=false"})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "unexpected EOF")
+}
+
+func TestStatLongElement(t *testing.T) {
+	// copy of the maximal length
+	enc := []byte("\x80\x02eazy\x80\x10\x0a\xfe\xff\xff\xff\xff\x00")
+
+	var s stats
+
+	err := s.collect(bytes.NewReader(enc))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), s.Lens[33])
+	assert.Equal(t, int64(1), s.Offs[33])
+	assert.Equal(t, int64(1), s.Elems[elemCopy].Count)
+
+	a, stdout, stderr := testApp(enc)
+	code := a.run([]string{"stat"})
+	assert.Equal(t, 0, code, "stderr: %s", stderr)
+	assert.Contains(t, stdout.String(), "lengths")
+}
@@ -0,0 +1,226 @@
+package eazy
+
+import (
+	"sync"
+)
+
+type (
+	// ConcurrentWriter is a Writer front end safe for concurrent use.
+	// Records from many goroutines are queued and compressed
+	// by a single owner goroutine, so producers don't wait for each other's compression.
+	// Records queued while the previous batch is being written
+	// are written together with one underlaying Write (group commit).
+	//
+	// The first underlaying writer error is sticky,
+	// all the following Writes return it.
+	// Records queued while the failed batch was being written are dropped,
+	// WriteSync and Close report the error for them.
+	ConcurrentWriter struct {
+		w *Writer
+
+		mu   sync.Mutex
+		cond sync.Cond // producers wait for queue space or commit
+		work sync.Cond // owner waits for records
+
+		q     []byte
+		ends  []int
+		limit int
+
+		seq    int64 // queued records
+		done   int64 // committed records
+		syncTo int64 // the last record waiting for Sync
+
+		err     error
+		errFrom int64 // the first record of the failed batch
+
+		closed bool
+		exited chan struct{}
+	}
+
+	syncer interface {
+		Sync() error
+	}
+)
+
+// NewConcurrentWriter creates ConcurrentWriter and starts its owner goroutine.
+// Close must be called to stop it.
+//
+// queueLimit is the size of queued uncompressed data
+// after which Write blocks until the owner takes the queue.
+// queueLimit <= 0 means no limit.
+//
+// w.FlushThreshold is set to -1, w must not be used directly after this call.
+func NewConcurrentWriter(w *Writer, queueLimit int) *ConcurrentWriter {
+	w.FlushThreshold = -1
+
+	c := &ConcurrentWriter{
+		w:      w,
+		limit:  queueLimit,
+		exited: make(chan struct{}),
+	}
+
+	c.cond.L = &c.mu
+	c.work.L = &c.mu
+
+	go c.run()
+
+	return c
+}
+
+// Write queues a copy of p to be compressed and written.
+// It returns as soon as the record is queued.
+func (c *ConcurrentWriter) Write(p []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err = c.push(p)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// WriteSync queues a copy of p and waits until it's written to the underlaying writer.
+// If the underlaying writer has Sync() error method (like *os.File)
+// it's called before WriteSync returns.
+// Other records committed in the same batch share the same Sync call.
+func (c *ConcurrentWriter) WriteSync(p []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seq, err := c.push(p)
+	if err != nil {
+		return 0, err
+	}
+
+	c.syncTo = seq
+
+	for c.done < seq {
+		c.cond.Wait()
+	}
+
+	if c.err != nil && seq >= c.errFrom {
+		return 0, c.err
+	}
+
+	return len(p), nil
+}
+
+// Close waits for all the queued records to be written and stops the owner goroutine.
+func (c *ConcurrentWriter) Close() error {
+	c.mu.Lock()
+
+	c.closed = true
+
+	c.work.Signal()
+	c.cond.Broadcast()
+
+	c.mu.Unlock()
+
+	<-c.exited
+
+	return c.err
+}
+
+// push queues p. c.mu must be held.
+func (c *ConcurrentWriter) push(p []byte) (seq int64, err error) {
+	for c.limit > 0 && len(c.q) >= c.limit && !c.closed && c.err == nil {
+		c.cond.Wait()
+	}
+
+	if c.closed {
+		return 0, ErrClosed
+	}
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	c.q = append(c.q, p...)
+	c.ends = append(c.ends, len(c.q))
+	c.seq++
+
+	c.work.Signal()
+
+	return c.seq, nil
+}
+
+func (c *ConcurrentWriter) run() {
+	defer close(c.exited)
+
+	var q []byte
+	var ends []int
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		for len(c.ends) == 0 && !c.closed {
+			c.work.Wait()
+		}
+
+		if len(c.ends) == 0 {
+			return
+		}
+
+		if c.err != nil {
+			// Writer may have been reset after the error,
+			// the records would start a new stream after a gap
+			c.q, c.ends = c.q[:0], c.ends[:0]
+			c.done = c.seq
+
+			c.cond.Broadcast()
+
+			continue
+		}
+
+		q, c.q = c.q, q[:0]
+		ends, c.ends = c.ends, ends[:0]
+
+		from := c.done + 1
+		seq := c.seq
+		sync := c.syncTo >= from
+
+		c.cond.Broadcast() // queue space is available
+
+		c.mu.Unlock()
+
+		err := c.commit(q, ends, sync)
+
+		c.mu.Lock()
+
+		if err != nil && c.err == nil {
+			c.err = err
+			c.errFrom = from
+		}
+
+		c.done = seq
+
+		c.cond.Broadcast()
+	}
+}
+
+func (c *ConcurrentWriter) commit(q []byte, ends []int, sync bool) (err error) {
+	st := 0
+
+	for _, end := range ends {
+		_, err = c.w.Write(q[st:end])
+		if err != nil {
+			return err
+		}
+
+		st = end
+	}
+
+	err = c.w.Flush()
+	if err != nil {
+		return err
+	}
+
+	if s, ok := c.w.Writer.(syncer); sync && ok {
+		err = s.Sync()
+	}
+
+	return err
+}
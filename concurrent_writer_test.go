@@ -0,0 +1,152 @@
+package eazy
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type slowSyncBuf struct {
+	lockedBuf
+	syncs int
+}
+
+func TestConcurrentWriter(t *testing.T) {
+	const N, M = 8, 50
+
+	var b slowSyncBuf
+
+	w := NewConcurrentWriter(NewWriter(&b, 1024, 32), 1024)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < M; j++ {
+				msg := []byte(fmt.Sprintf("producer %d message %3d\n", i, j))
+
+				var n int
+				var err error
+
+				if j%10 == 9 {
+					n, err = w.WriteSync(msg)
+				} else {
+					n, err = w.Write(msg)
+				}
+
+				assert.NoError(t, err)
+				assert.Equal(t, len(msg), n)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	err := w.Close()
+	require.NoError(t, err)
+
+	_, err = w.Write([]byte("closed"))
+	assert.ErrorIs(t, err, ErrClosed)
+
+	t.Logf("writes %d  syncs %d  records %d", b.n, b.syncs, N*M)
+
+	assert.Less(t, b.n, N*M)
+	assert.NotZero(t, b.syncs)
+	assert.LessOrEqual(t, b.syncs, N*M/10)
+
+	data, err := io.ReadAll(NewReaderBytes(b.b))
+	require.NoError(t, err)
+
+	for i := 0; i < N; i++ {
+		for j := 0; j < M; j++ {
+			assert.Contains(t, string(data), fmt.Sprintf("producer %d message %3d\n", i, j))
+		}
+	}
+}
+
+func TestConcurrentWriterError(t *testing.T) {
+	var b Buf
+
+	w := NewConcurrentWriter(NewWriter(&failWriter{w: &b, fail: []int{0}}, 1024, 32), 0)
+
+	_, err := w.WriteSync([]byte("message"))
+	assert.ErrorIs(t, err, errFail)
+
+	_, err = w.Write([]byte("message"))
+	assert.ErrorIs(t, err, errFail)
+
+	err = w.Close()
+	assert.ErrorIs(t, err, errFail)
+}
+
+func TestConcurrentWriterErrorQueued(t *testing.T) {
+	gw := &gateWriter{entered: make(chan struct{}), release: make(chan struct{})}
+
+	w := NewConcurrentWriter(NewWriter(gw, 1024, 32), 0)
+
+	_, err := w.Write([]byte("first"))
+	require.NoError(t, err)
+
+	<-gw.entered
+
+	// queued while the failing batch is being written
+	_, err = w.Write([]byte("second"))
+	require.NoError(t, err)
+
+	close(gw.release)
+
+	_, err = w.WriteSync([]byte("third"))
+	assert.ErrorIs(t, err, errFail)
+
+	err = w.Close()
+	assert.ErrorIs(t, err, errFail)
+
+	assert.Equal(t, 1, gw.n, "nothing is written after the error")
+	assert.Empty(t, gw.b)
+}
+
+type gateWriter struct {
+	entered, release chan struct{}
+
+	n int
+	b Buf
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	w.n++
+
+	if w.n == 1 {
+		close(w.entered)
+		<-w.release
+
+		return 0, errFail
+	}
+
+	w.b = append(w.b, p...)
+
+	return len(p), nil
+}
+
+func (b *slowSyncBuf) Write(p []byte) (int, error) {
+	time.Sleep(100 * time.Microsecond)
+
+	return b.lockedBuf.Write(p)
+}
+
+func (b *slowSyncBuf) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.syncs++
+
+	return nil
+}
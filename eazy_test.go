@@ -2,13 +2,17 @@ package eazy
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -23,8 +27,25 @@ type (
 		Buf
 		R int
 	}
+
+	writerFunc func(p []byte) (int, error)
+
+	// cancelReader calls cancel once Reader is drained.
+	cancelReader struct {
+		*bytes.Reader
+		cancel func()
+	}
+
+	// failWriter fails Writes according to fail list.
+	// Zero means error without writing anything, n > 0 means short write.
+	failWriter struct {
+		w    io.Writer
+		fail []int
+	}
 )
 
+var errFail = errors.New("write failed")
+
 var (
 	fileFlag       = flag.String("tlog-file", "log.tlog", "file with tlog logs")
 	ratioEstimator = flag.Int("ratio-estimator", 0, "ratio estimator iterations to run")
@@ -414,6 +435,119 @@ func TestBreak(t *testing.T) {
 	assert.Equal(t, 0, n)
 }
 
+func TestWriteTo(t *testing.T) {
+	const B = 32
+
+	var buf Buf
+
+	w := NewWriter(&buf, B, B>>1)
+
+	_, err := w.Write([]byte("message1"))
+	assert.NoError(t, err)
+
+	err = w.WriteBreak()
+	assert.NoError(t, err)
+
+	_, err = w.Write([]byte("qwessage2"))
+	assert.NoError(t, err)
+
+	r := NewReaderBytes(buf)
+
+	var out bytes.Buffer
+
+	n, err := r.WriteTo(&out)
+	assert.ErrorIs(t, err, ErrBreak)
+	assert.Equal(t, int64(8), n)
+	assert.Equal(t, "message1", out.String())
+
+	out.Reset()
+
+	n, err = r.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), n)
+	assert.Equal(t, "qwessage2", out.String())
+
+	// copies wrapping around the window, runlen and zero regions
+
+	buf = buf[:0]
+	w.Reset(&buf)
+
+	var exp []byte
+
+	for i := 0; i < 20; i++ {
+		p := []byte(fmt.Sprintf("msg %3d aaaaaaaaaaaa %s", i%3, make([]byte, i)))
+
+		exp = append(exp, p...)
+
+		_, err = w.Write(p)
+		require.NoError(t, err)
+	}
+
+	r.ResetBytes(buf)
+	out.Reset()
+
+	n, err = r.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(exp)), n)
+	assert.Equal(t, exp, out.Bytes())
+
+	// short write
+
+	r.ResetBytes(buf)
+	out.Reset()
+
+	n, err = r.WriteTo(&failWriter{w: &out, fail: []int{3}})
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, exp[:3], out.Bytes())
+}
+
+func TestNext(t *testing.T) {
+	var buf Buf
+
+	w := NewWriter(&buf, 64, 16)
+
+	var exp []byte
+
+	for i := 0; i < 20; i++ {
+		p := []byte(fmt.Sprintf("message %d %s", i%4, strings.Repeat("a", i)))
+		exp = append(exp, p...)
+
+		_, err := w.Write(p)
+		require.NoError(t, err)
+
+		if i == 10 {
+			err = w.WriteBreak()
+			require.NoError(t, err)
+		}
+	}
+
+	r := NewReaderBytes(buf)
+
+	var out []byte
+	var breaks int
+
+	for {
+		p, err := r.Next()
+		if errors.Is(err, ErrBreak) {
+			breaks++
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+		require.NotEmpty(t, p)
+		require.LessOrEqual(t, len(p), 64)
+
+		out = append(out, p...)
+	}
+
+	assert.Equal(t, 1, breaks)
+	assert.Equal(t, exp, out)
+}
+
 func TestReaderRequireMagic(t *testing.T) {
 	var b BufReader
 
@@ -511,6 +645,392 @@ func TestFlushReset(t *testing.T) {
 	assert.Equal(t, Buf{Meta, MetaReset, 10, Literal | 3, '4', '5', '6'}, b.Buf)
 }
 
+func TestRollback(t *testing.T) {
+	var b Buf
+
+	fw := &failWriter{w: &b}
+
+	w := NewWriter(fw, 1024, 32)
+	w.Rollback = true
+
+	msgs := []string{"first_message", "second_message", "third_message", "fourth_message"}
+
+	// fail the first attempt of the header and the first record
+
+	fw.fail = append(fw.fail, 0)
+
+	n, err := w.Write([]byte(msgs[0]))
+	assert.ErrorIs(t, err, errFail)
+	assert.Equal(t, 0, n)
+	assert.Len(t, b, 0)
+
+	n, err = w.Write([]byte(msgs[0]))
+	assert.NoError(t, err)
+	assert.Equal(t, len(msgs[0]), n)
+
+	// fail without writing anything
+
+	fw.fail = append(fw.fail, 0)
+
+	n, err = w.Write([]byte(msgs[1]))
+	assert.ErrorIs(t, err, errFail)
+	assert.Equal(t, 0, n)
+
+	n, err = w.Write([]byte(msgs[1]))
+	assert.NoError(t, err)
+	assert.Equal(t, len(msgs[1]), n)
+
+	// short write, record is accepted
+
+	fw.fail = append(fw.fail, 3)
+
+	n, err = w.Write([]byte(msgs[2]))
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.Equal(t, len(msgs[2]), n)
+
+	err = w.Flush()
+	assert.NoError(t, err)
+
+	n, err = w.Write([]byte(msgs[3]))
+	assert.NoError(t, err)
+	assert.Equal(t, len(msgs[3]), n)
+
+	r := NewReaderBytes(b)
+	p := make([]byte, 100)
+
+	n, err = r.Read(p)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, strings.Join(msgs, ""), string(p[:n]))
+
+	if t.Failed() {
+		t.Logf("dump\n%s", Dump(b))
+	}
+}
+
+func TestShortWriteReset(t *testing.T) {
+	var b Buf
+
+	fw := &failWriter{w: &b, fail: []int{3}}
+
+	w := NewWriter(fw, 1024, 32)
+
+	_, err := w.Write([]byte("message"))
+	assert.ErrorIs(t, err, io.ErrShortWrite)
+	assert.True(t, w.isreset())
+}
+
+func TestReadFrom(t *testing.T) {
+	data := []byte(strings.Repeat("some message 0123456789 ", 10))
+
+	var b Buf
+	var writes int
+
+	cw := writerFunc(func(p []byte) (int, error) {
+		writes++
+		return b.Write(p)
+	})
+
+	w := NewWriter(cw, 1024, 32)
+	w.ChunkSize = 64
+	w.ChunkBreak = true
+
+	n, err := w.ReadFrom(iotest.OneByteReader(bytes.NewReader(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+
+	chunks := (len(data) + 63) / 64
+
+	assert.Equal(t, 2*chunks-1, writes) // chunks and breaks
+
+	r := NewReaderBytes(b)
+	p := make([]byte, 100)
+
+	var out []byte
+	var breaks int
+
+	for {
+		m, err := r.Read(p)
+		out = append(out, p[:m]...)
+
+		if errors.Is(err, ErrBreak) {
+			assert.Equal(t, 64*(breaks+1), len(out))
+			breaks++
+
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, chunks-1, breaks)
+	assert.Equal(t, data, out)
+
+	// read error
+
+	b = b[:0]
+	w.Reset(cw)
+	w.ChunkBreak = false
+	w.FlushThreshold = -1
+	w.ChunkFlush = true
+	writes = 0
+
+	n, err = w.ReadFrom(io.MultiReader(bytes.NewReader(data[:100]), iotest.ErrReader(errFail)))
+	assert.ErrorIs(t, err, errFail)
+	assert.Equal(t, int64(100), n)
+	assert.Equal(t, 2, writes)
+
+	out, err = io.ReadAll(NewReaderBytes(b))
+	assert.NoError(t, err)
+	assert.Equal(t, data[:100], out)
+
+	// write error
+
+	b = b[:0]
+
+	writes = 0
+
+	w = NewWriter(writerFunc(func(p []byte) (int, error) {
+		if writes++; writes == 3 {
+			return 0, errFail
+		}
+
+		return b.Write(p)
+	}), 1024, 32)
+	w.ChunkSize = 64
+	w.Rollback = true
+
+	n, err = w.ReadFrom(bytes.NewReader(data))
+	assert.ErrorIs(t, err, errFail)
+	assert.Equal(t, int64(128), n)
+}
+
+func TestContext(t *testing.T) {
+	data := []byte(strings.Repeat("some message 0123456789 ", 100))
+
+	var b Buf
+
+	w := NewWriter(&b, 1024, 32)
+	w.ChunkSize = 256
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// cancels once the first 4 chunks are read
+	rd := io.MultiReader(&cancelReader{Reader: bytes.NewReader(data[:1024]), cancel: cancel}, bytes.NewReader(data[1024:]))
+
+	n, err := w.ReadFromContext(ctx, rd)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(1024), n)
+
+	n, err = w.ReadFromContext(context.Background(), bytes.NewReader(data[1024:]))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)-1024), n)
+
+	// reading
+
+	ctx, cancel = context.WithCancel(context.Background())
+
+	r := NewReaderBytes(b)
+
+	p := make([]byte, 100)
+
+	m, err := r.ReadContext(ctx, p)
+	assert.NoError(t, err)
+	assert.Equal(t, len(p), m)
+
+	cancel()
+
+	m, err = r.ReadContext(ctx, p)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, m)
+
+	var out Buf
+
+	ctx, cancel = context.WithCancel(context.Background())
+
+	n, err = r.WriteToContext(ctx, writerFunc(func(p []byte) (int, error) {
+		cancel()
+		return out.Write(p)
+	}))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int64(len(out)), n)
+	assert.NotZero(t, n)
+	assert.Less(t, n, int64(len(data)-100))
+
+	n, err = r.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, data[100:], []byte(out))
+}
+
+func TestFollow(t *testing.T) {
+	data := []byte(strings.Repeat("some message 0123456789 ", 100))
+
+	var enc Buf
+
+	w := NewWriter(&enc, 1024, 32)
+
+	for i := 0; i < len(data); i += 300 {
+		_, err := w.Write(data[i : i+300])
+		require.NoError(t, err)
+	}
+
+	var src BufReader
+
+	r := NewReader(&src)
+	r.Follow = true
+
+	var out []byte
+	p := make([]byte, 100)
+
+	for k := 0; k < len(enc); {
+		k += 7
+		if k > len(enc) {
+			k = len(enc)
+		}
+
+		src.Buf = enc[:k]
+
+		for {
+			n, err := r.Read(p)
+			out = append(out, p[:n]...)
+
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			require.NoError(t, err, "k %d", k)
+		}
+	}
+
+	assert.Equal(t, string(data), string(out))
+
+	r = NewReader(bytes.NewReader(enc[:len(enc)-3]))
+
+	_, err := io.ReadAll(r)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReaderLimits(t *testing.T) {
+	var b Buf
+
+	w := NewWriter(&b, 64*KiB, 1024)
+
+	_, err := w.Write(make([]byte, 10*MiB))
+	require.NoError(t, err)
+
+	r := NewReaderBytes(b)
+	r.OutputLimit = 1 * MiB
+
+	n, err := io.Copy(io.Discard, r)
+	assert.ErrorIs(t, err, ErrOutputLimit)
+	assert.Equal(t, int64(1*MiB), n)
+
+	var lerr *LimitError
+	if assert.ErrorAs(t, err, &lerr) {
+		assert.Equal(t, int64(1*MiB), lerr.Out)
+	}
+
+	r.ResetBytes(b)
+	r.OutputLimit = 10 * MiB
+
+	n, err = io.Copy(io.Discard, r)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10*MiB), n)
+
+	r.ResetBytes(b)
+	r.OutputLimit = 0
+	r.RatioLimit = 100
+
+	n, err = io.Copy(io.Discard, r)
+	assert.ErrorIs(t, err, ErrRatioLimit)
+	assert.GreaterOrEqual(t, n, int64(1*MiB))
+	assert.Less(t, n, int64(10*MiB))
+
+	//
+
+	data := testLogData(4 * MiB)
+
+	b = b[:0]
+	w.Reset(&b)
+
+	_, err = w.Write(data)
+	require.NoError(t, err)
+
+	r.ResetBytes(b)
+
+	n, err = io.Copy(io.Discard, r)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+}
+
+func TestCorruptError(t *testing.T) {
+	var b Buf
+
+	w := NewWriter(&b, 1024, 32)
+
+	_, err := w.Write([]byte("some literal data"))
+	require.NoError(t, err)
+
+	hdr := len(w.appendHeader(nil))
+	st := len(b)
+
+	b = w.e.Tag(b, Copy, 4)
+	b = w.e.Offset(b, 2000, 4)
+
+	check := func(err error, target error, in, out int64, kind string) {
+		t.Helper()
+
+		assert.ErrorIs(t, err, target)
+
+		var cerr *CorruptError
+		if assert.ErrorAs(t, err, &cerr) {
+			assert.Equal(t, in, cerr.In)
+			assert.Equal(t, out, cerr.Out)
+			assert.Equal(t, kind, cerr.Kind)
+		}
+	}
+
+	// Reader and Decode report the same element start
+
+	for _, tc := range []struct {
+		b      []byte
+		target error
+		in     int64
+		out    int64
+		kind   string
+	}{
+		{b, ErrOverflow, int64(st), 17, "copy"},
+		{b[:st-2], io.ErrUnexpectedEOF, int64(hdr), 0, "literal"}, // truncated literal
+		{b[hdr:st], ErrMissedMeta, 0, 0, "literal"},               // no header
+	} {
+		r := NewReaderBytes(tc.b)
+
+		_, err = io.ReadAll(r)
+		check(err, tc.target, tc.in, tc.out, tc.kind)
+
+		_, err = Decode(nil, tc.b)
+		check(err, tc.target, tc.in, tc.out, tc.kind)
+	}
+
+	// limit is not a corruption
+
+	r := NewReaderBytes(b)
+	r.BlockSizeLimit = 512
+
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrBlockSizeOverLimit)
+
+	var lerr *LimitError
+	if assert.ErrorAs(t, err, &lerr) {
+		assert.Equal(t, LimitError{Err: ErrBlockSizeOverLimit, In: int64(hdr - 3), Out: 0}, *lerr) // reset meta
+	}
+
+	var cerr *CorruptError
+	assert.False(t, errors.As(err, &cerr))
+}
+
 func TestIntersectionLong(t *testing.T) {
 	testIntersection(t, func(rnd *rand.Rand, msg []byte) []byte {
 		msg2 := make([]byte, 0x20)
@@ -746,6 +1266,75 @@ func testGiantLiteral(t *testing.T, f func(rnd *rand.Rand, bs int) []byte) {
 	}
 }
 
+func TestSplitLongElements(t *testing.T) {
+	assert.NotPanics(t, func() { Encoder{}.Tag(nil, Literal, maxLen) })
+	assert.Panics(t, func() { Encoder{}.Tag(nil, Literal, maxLen+1) })
+
+	defer func(l int) {
+		maxLen = l
+	}(maxLen)
+
+	maxLen = 100
+
+	rnd := rand.New(rand.NewSource(0))
+
+	msg := make([]byte, 1000)
+
+	for i := range msg[:300] {
+		msg[i] = ' ' + byte(rnd.Intn(0x78-0x20))
+	}
+
+	for i := 600; i < len(msg); i += 2 {
+		copy(msg[i:], "ab")
+	}
+
+	var b Buf
+
+	w := NewWriter(&b, 1024, 512)
+
+	for _, p := range [][]byte{msg, msg} {
+		n, err := w.Write(p)
+		assert.NoError(t, err)
+		assert.Equal(t, len(p), n)
+	}
+
+	r := NewReaderBytes(b)
+	p := make([]byte, 2*len(msg)+1)
+
+	n, err := r.Read(p)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 2*len(msg), n)
+	assert.Equal(t, msg, p[:len(msg)])
+	assert.Equal(t, msg, p[len(msg):n])
+
+	var d Decoder
+
+	elements := 0
+	i := len(Magic) + 3
+
+	for i < len(b) {
+		tag, l, j, err := d.Tag(b, i)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, l, maxLen)
+
+		if tag == Literal {
+			j += l
+		} else {
+			_, j, err = d.Offset(b, j, l)
+			require.NoError(t, err)
+		}
+
+		i = j
+		elements++
+	}
+
+	assert.Greater(t, elements, 2*len(msg)/maxLen)
+
+	if t.Failed() {
+		t.Logf("dump\n%s", Dump(b))
+	}
+}
+
 func TestUnsupportedVersion(t *testing.T) {
 	var b Buf
 
@@ -1010,6 +1599,183 @@ func TestDumper(t *testing.T) {
 	t.Logf("compressed\n%s", hex.Dump(b.Buf))
 	t.Logf("dumper\n%s", b2)
 	t.Logf("debug\n%s", b3)
+
+	// elements split between writes
+
+	var elems [2][]string
+
+	for i, r := range []io.Reader{bytes.NewReader(b.Buf), iotest.OneByteReader(bytes.NewReader(b.Buf))} {
+		d = NewDumper(nil)
+		d.Debug = func(ipos, iend, opos int64, tag byte, l, x int) {
+			if tag != 'p' { // padding is reported by parts
+				elems[i] = append(elems[i], fmt.Sprintf("%x %x %c %x %x", ipos, opos, tag, l, x))
+			}
+		}
+
+		_, err = d.ReadFrom(r)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, elems[0], elems[1])
+}
+
+func TestDumperKeepWindow(t *testing.T) {
+	var b Buf
+
+	w := NewWriter(&b, 1024, 32)
+
+	data := []byte("first message, first message\n" + strings.Repeat("a", 40) + "\n")
+	data = append(data, make([]byte, 100)...)
+
+	_, _ = w.Write(data[:len(data)/2])
+	_, _ = w.Write(data[len(data)/2:])
+
+	var lines []int
+
+	for _, r := range []io.Reader{bytes.NewReader(b), iotest.OneByteReader(bytes.NewReader(b))} {
+		var text, dec Buf
+		var copies int
+
+		d := NewDumper(&text)
+		d.KeepWindow = true
+		d.Debug = func(ipos, iend, opos int64, tag byte, l, off int) {
+			switch tag {
+			case 'l':
+				dec = append(dec, b[iend:iend+int64(l)]...)
+			case 'c':
+				src, p := d.Copied()
+				assert.Equal(t, data[src:src+int64(l)], p)
+				assert.Equal(t, data[opos:opos+int64(l)], p)
+
+				dec = append(dec, p...)
+				copies++
+			}
+		}
+
+		_, err := d.ReadFrom(r)
+		assert.NoError(t, err)
+
+		assert.Equal(t, data, []byte(dec))
+		assert.NotZero(t, copies)
+		assert.Contains(t, string(text), `copy    d  off    f  from 0  "first message"`)
+		assert.Contains(t, string(text), `off    1  (long)  from 1d  "aaaa`)
+		lines = append(lines, bytes.Count(text, []byte{'\n'}))
+	}
+
+	assert.Equal(t, lines[0], lines[1], "elements split between writes are printed once")
+
+	// window is limited
+
+	var e Encoder
+
+	big := e.Meta(nil, MetaReset, 1)
+	big = append(big, 30)
+
+	d := NewDumper(nil)
+	d.KeepWindow = true
+	d.BlockSizeLimit = 1 * MiB
+
+	_, err := d.Write(big)
+	assert.ErrorIs(t, err, ErrBlockSizeOverLimit)
+
+	long := e.Tag(w.appendHeader(nil), Copy, 2*MiB)
+	long = e.Offset(long, 1, 2*MiB)
+
+	d = NewDumper(nil)
+	d.KeepWindow = true
+	d.BlockSizeLimit = 1 * MiB
+
+	_, err = d.Write(long)
+	assert.ErrorIs(t, err, ErrBlockSizeOverLimit)
+
+	far := e.Tag(w.appendHeader(nil), Copy, 4)
+	far = e.Offset(far, 2000, 4) // window is 1024
+
+	d = NewDumper(nil)
+	d.KeepWindow = true
+
+	_, err = d.Write(far)
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestOptions(t *testing.T) {
+	var b Buf
+
+	w, err := NewWriterOptions(&b, DefaultOptions())
+	require.NoError(t, err)
+	assert.Len(t, w.block, DefaultBlockSize)
+	assert.Len(t, w.ht, DefaultHashTableSize)
+	assert.True(t, w.AppendMagic)
+
+	// zero value keeps the default
+	w0, err := NewWriterOptions(nil, Options{})
+	require.NoError(t, err)
+	assert.True(t, w0.AppendMagic)
+	assert.True(t, bytes.HasPrefix(Encode(nil, []byte("message"), Options{}), []byte(Magic)))
+
+	w0, err = NewWriterOptions(nil, Options{NoMagic: true})
+	require.NoError(t, err)
+	assert.False(t, w0.AppendMagic)
+	assert.False(t, bytes.HasPrefix(Encode(nil, []byte("message"), Options{NoMagic: true}), []byte(Magic)))
+
+	_, err = w.Write([]byte("message"))
+	assert.NoError(t, err)
+
+	r, err := NewReaderOptions(&BufReader{Buf: b}, Options{RequireMagic: true})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultBlockSizeLimit, r.BlockSizeLimit)
+	assert.Equal(t, DefaultBufferSize, r.BufferSize)
+
+	p := make([]byte, 16)
+
+	n, err := r.Read(p)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, []byte("message"), p[:n])
+
+	r, err = NewReaderOptions(nil, Options{BlockSizeLimit: -1})
+	require.NoError(t, err)
+	assert.Equal(t, 0, r.BlockSizeLimit)
+
+	for _, tc := range []struct {
+		opts Options
+		err  error
+	}{
+		{Options{BlockSize: 1000}, ErrBadBlockSize},
+		{Options{BlockSize: 16}, ErrBadBlockSize},
+		{Options{BlockSize: 1 << 32}, ErrBadBlockSize},
+		{Options{HashTableSize: 100}, ErrBadHashTableSize},
+		{Options{HashTableSize: 2}, ErrBadHashTableSize},
+		{Options{FlushThreshold: -2}, ErrBadOption},
+		{Options{BlockSizeLimit: -2}, ErrBadOption},
+		{Options{BufferSize: -1}, ErrBadOption},
+		{Options{BlockSize: 32 * MiB}, ErrBadOption},
+		{Options{BlockSize: 4 * KiB, BlockSizeLimit: 1 * KiB}, ErrBadOption},
+	} {
+		_, err = NewWriterOptions(&b, tc.opts)
+		assert.ErrorIs(t, err, tc.err, "%+v", tc.opts)
+
+		_, err = NewReaderOptions(nil, tc.opts)
+		assert.ErrorIs(t, err, tc.err, "%+v", tc.opts)
+	}
+
+	assert.Panics(t, func() { NewWriter(&b, 1000, 32) })
+
+	r, err = NewReaderOptions(nil, Options{BlockSize: 32 * MiB, BlockSizeLimit: -1})
+	require.NoError(t, err)
+	assert.Equal(t, 0, r.BlockSizeLimit)
+
+	w = NewWriter(&b, 1024, 32)
+
+	err = w.ResetSize(&b, 1000, 32)
+	assert.ErrorIs(t, err, ErrBadBlockSize)
+	assert.Len(t, w.block, 1024)
+
+	err = w.ResetSize(&b, 2048, 2)
+	assert.ErrorIs(t, err, ErrBadHashTableSize)
+
+	err = w.ResetSize(&b, 2048, 64)
+	assert.NoError(t, err)
+	assert.Len(t, w.block, 2048)
 }
 
 func TestOnFile(t *testing.T) {
@@ -1219,34 +1985,60 @@ func BenchmarkDecompressFile(b *testing.B) {
 		written += n
 	}
 
-	b.ReportAllocs()
-	b.ResetTimer()
+	benchmarkDecompress(b, encoded, testData[:written], testsCount)
+}
+
+func BenchmarkDecompressLog(b *testing.B) {
+	data := testLogData(4 * MiB)
+
+	encoded := make(Buf, 0, len(data)/2)
+	w := NewWriter(&encoded, BlockSize, HTSize)
+
+	for i := 0; i < len(data); i += 200 {
+		end := i + 200
+		if end > len(data) {
+			end = len(data)
+		}
+
+		_, err := w.Write(data[i:end])
+		if err != nil {
+			b.Fatalf("write: %v", err)
+		}
+	}
 
-	b.ReportMetric(float64(written)/float64(len(encoded)), "ratio")
+	benchmarkDecompress(b, encoded, data, 1)
+}
 
-	//	var decoded []byte
-	decoded := make(Buf, 0, len(testData))
+// benchmarkDecompress decodes the whole stream once per per ops.
+func benchmarkDecompress(b *testing.B, encoded, data []byte, per int) {
+	decoded := make(Buf, 0, len(data))
 	buf := make([]byte, 4096)
 	r := NewReaderBytes(encoded)
 
-	for i := 0; i < b.N/testsCount; i++ {
-		r.ResetBytes(encoded)
-		decoded = decoded[:0]
+	run := func(b *testing.B, rd io.Reader) {
+		b.ReportAllocs()
+		b.ReportMetric(float64(len(data))/float64(len(encoded)), "ratio")
 
-		_, err = io.CopyBuffer(&decoded, r, buf)
-		assert.NoError(b, err)
-	}
+		for i := 0; i < b.N/per || i == 0; i++ {
+			r.ResetBytes(encoded)
+			decoded = decoded[:0]
 
-	//	b.Logf("decoded %x", len(decoded))
+			_, err := io.CopyBuffer(&decoded, rd, buf)
+			assert.NoError(b, err)
+		}
 
-	b.SetBytes(int64(decoded.Len() / testsCount))
+		b.SetBytes(int64(len(data) / per))
 
-	decLen := len(testData)
-	if decLen > decoded.Len() {
-		decLen = decoded.Len()
+		assert.True(b, bytes.Equal(data, decoded), "decoded data mismatch")
 	}
 
-	assert.Equal(b, testData[:decLen], decoded.Bytes())
+	b.Run("Read", func(b *testing.B) {
+		run(b, struct{ io.Reader }{r}) // hide WriteTo
+	})
+
+	b.Run("WriteTo", func(b *testing.B) {
+		run(b, r)
+	})
 }
 
 func loadTestFile(tb testing.TB, f string) (err error) {
@@ -1312,7 +2104,7 @@ func FuzzWriter(f *testing.F) {
 	)
 
 	f.Fuzz(func(t *testing.T, p0, p1, p2 []byte) {
-		var wbuf, rbuf bytes.Buffer
+		var wbuf, rbuf, wtbuf bytes.Buffer
 		buf := make([]byte, 16)
 
 		w := NewWriter(&wbuf, 512, 32)
@@ -1337,10 +2129,14 @@ func FuzzWriter(f *testing.F) {
 
 		r := NewReaderBytes(wbuf.Bytes())
 
-		m, err := io.CopyBuffer(&rbuf, r, buf)
+		m, err := io.CopyBuffer(&rbuf, struct{ io.Reader }{r}, buf) // hide WriteTo to read by small chunks
 		assert.NoError(t, err)
 		assert.Equal(t, len(p0)+len(p1)+len(p2), int(m))
 
+		_, err = NewReaderBytes(wbuf.Bytes()).WriteTo(&wtbuf)
+		assert.NoError(t, err)
+		assert.Equal(t, rbuf.String(), wtbuf.String())
+
 		i := 0
 		for _, p := range [][]byte{p0, p1, p2} {
 			assert.Equal(t, p, rbuf.Bytes()[i:i+len(p)])
@@ -1504,6 +2300,34 @@ func (b *Buf) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func (r *cancelReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	if r.Len() == 0 {
+		r.cancel()
+	}
+
+	return
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if len(w.fail) == 0 {
+		return w.w.Write(p)
+	}
+
+	n := w.fail[0]
+	w.fail = w.fail[1:]
+
+	n, _ = w.w.Write(p[:n])
+
+	if n == 0 {
+		return 0, errFail
+	}
+
+	return n, nil
+}
+
 func (b *BufReader) Reset(p []byte) {
 	b.Buf = p
 	b.R = 0
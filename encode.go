@@ -0,0 +1,228 @@
+package eazy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"sync"
+)
+
+var encoders sync.Pool
+
+// Encode compresses src as a complete stream and appends it to dst.
+// Window is shrunk to the src size if it's smaller than opts.BlockSize,
+// so the decoder needs no more memory than the data size.
+//
+// Writers are pooled, so Encode doesn't allocate if dst has enough capacity.
+// FlushThreshold is ignored.
+// Encode panics if opts are not valid.
+func Encode(dst, src []byte, opts Options) []byte {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		panic(err)
+	}
+
+	bs := 32
+	if len(src) > bs {
+		bs = 1 << bits.Len(uint(len(src)-1))
+	}
+
+	if bs > opts.BlockSize {
+		bs = opts.BlockSize
+	}
+
+	w, ok := encoders.Get().(*Writer)
+	if !ok {
+		w = &Writer{
+			e: Encoder{
+				Ver: Version,
+			},
+		}
+	}
+
+	defer encoders.Put(w)
+
+	_ = w.ResetSize(nil, bs, opts.HashTableSize) // sizes are checked by withDefaults
+	w.AppendMagic = !opts.NoMagic
+	w.FlushThreshold = -1
+
+	// header is appended here as dst may be not empty
+	w.b = w.appendHeader(dst)
+
+	_, _ = w.Write(src)
+
+	dst = w.b
+	w.b = nil
+
+	return dst
+}
+
+// Decode decompresses the whole src and appends the result to dst.
+// dst is used as the window itself, so there is no intermediate copy
+// and no allocation if dst has enough capacity.
+//
+// src may be a concatenation of streams. Break markers are skipped.
+// Data decoded before an error is returned along with it.
+//
+// Decode has no output limit, a single copy element can expand to 4GiB.
+// Use DecodeLimit for untrusted input.
+func Decode(dst, src []byte) ([]byte, error) {
+	return DecodeLimit(dst, src, 0)
+}
+
+// DecodeLimit is Decode which returns LimitError with ErrOutputLimit
+// instead of appending more than limit bytes to dst.
+// Zero limit means no limit.
+func DecodeLimit(dst, src []byte, limit int64) ([]byte, error) {
+	var d Decoder
+
+	base := -1 // current stream start in dst
+	bs := 0
+	start := len(dst)
+
+	corrupt := func(err error, st int) error {
+		return &CorruptError{
+			Err:  err,
+			In:   int64(st),
+			Out:  int64(len(dst) - start),
+			Kind: tagKind(src, st),
+		}
+	}
+
+	for i := 0; ; {
+		for i < len(src) && src[i] == Padding {
+			i++
+		}
+
+		if i == len(src) {
+			return dst, nil
+		}
+
+		e, next, err := parseElement(d, src, i)
+		if errors.Is(err, ErrShortBuffer) {
+			return dst, corrupt(io.ErrUnexpectedEOF, i)
+		}
+		if err != nil {
+			return dst, corrupt(err, i)
+		}
+
+		if e.tag == 'm' {
+			bs, err = decodeMeta(&d, e, src[next-e.l:next], bs)
+			if err != nil {
+				return dst, corrupt(err, i)
+			}
+
+			if e.off == MetaReset {
+				base = len(dst)
+			}
+
+			i = next
+
+			continue
+		}
+
+		if base < 0 {
+			return dst, corrupt(ErrMissedMeta, i)
+		}
+
+		if limit != 0 && int64(len(dst)-start)+int64(e.l) > limit {
+			return dst, &LimitError{Err: ErrOutputLimit, In: int64(i), Out: int64(len(dst) - start)}
+		}
+
+		if e.tag == 'l' {
+			if next+e.l > len(src) {
+				return dst, corrupt(io.ErrUnexpectedEOF, i)
+			}
+
+			dst = append(dst, src[next:next+e.l]...)
+			i = next + e.l
+
+			continue
+		}
+
+		if e.off > bs {
+			return dst, corrupt(ErrOverflow, i)
+		}
+
+		dst = decodeCopy(dst, base, e.off, e.l)
+		i = next
+	}
+}
+
+// decodeMeta checks meta data p the same way Reader does.
+// It returns the new block size.
+func decodeMeta(d *Decoder, e element, p []byte, bs int) (int, error) {
+	tagLen := [...]int{4, 1, 1, 0}
+
+	if j := e.off >> 3; j < len(tagLen) && e.l != tagLen[j] {
+		return bs, ErrUnsupportedMeta
+	}
+
+	switch e.off {
+	case MetaMagic:
+		if !bytes.Equal(p, []byte("eazy")) {
+			return bs, ErrBadMagic
+		}
+	case MetaVer:
+		d.Ver = int(p[0])
+		if d.Ver > Version {
+			return bs, fmt.Errorf("%w: %v", ErrUnsupportedVersion, d.Ver)
+		}
+	case MetaReset:
+		if p[0] > 32 {
+			return bs, ErrOverflow
+		}
+
+		return 1 << p[0], nil
+	case MetaBreak:
+	default:
+		return bs, fmt.Errorf("%w: 0x%x", ErrUnsupportedMeta, e.off)
+	}
+
+	return bs, nil
+}
+
+// decodeCopy appends l bytes copied from dist bytes back.
+// Zero dist is a zero region.
+// Data before the stream start at base is zeros as in the Reader window.
+func decodeCopy(dst []byte, base, dist, l int) []byte {
+	n := len(dst)
+
+	if cap(dst)-n < l {
+		dst = append(dst, make([]byte, l)...)
+	}
+
+	dst = dst[:n+l]
+
+	first := l
+	if dist != 0 && first > dist {
+		first = dist
+	}
+
+	s := n - dist
+	j := 0
+
+	if dist == 0 || s < base {
+		j = base - s
+		if dist == 0 || j > first {
+			j = first
+		}
+
+		for k := 0; k < j; {
+			k += copy(dst[n+k:n+j], zeros)
+		}
+	}
+
+	if j < first {
+		copy(dst[n+j:n+first], dst[s+j:])
+	}
+
+	// runlen: the first dist bytes repeat, copy them doubling the chunk
+	for j = first; j < l; {
+		j += copy(dst[n+j:n+l], dst[n:n+j])
+	}
+
+	return dst
+}
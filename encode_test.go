@@ -0,0 +1,161 @@
+package eazy
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	opts := DefaultOptions()
+
+	for _, data := range [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"),
+		make([]byte, 1000),
+		bytes.Repeat([]byte("abcdefg 0123456789 "), 100),
+		testLogData(300 * KiB),
+	} {
+		enc := Encode([]byte("prefix"), data, opts)
+		require.Equal(t, "prefix", string(enc[:6]))
+
+		dec, err := Decode([]byte("prefix"), enc[6:])
+		require.NoError(t, err)
+		assert.Equal(t, "prefix", string(dec[:6]))
+		assert.Equal(t, data, dec[6:], "len %d", len(data))
+
+		r := NewReaderBytes(enc[6:])
+
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, len(data), len(out))
+		assert.True(t, bytes.Equal(data, out))
+	}
+}
+
+func TestEncodeSmallBlock(t *testing.T) {
+	opts := DefaultOptions()
+
+	enc := Encode(nil, []byte("short message"), opts)
+
+	r := NewReaderBytes(enc)
+
+	_, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, 32, len(r.block))
+
+	opts.BlockSize = 1 * KiB
+
+	enc = Encode(nil, testLogData(10*KiB), opts)
+
+	r = NewReaderBytes(enc)
+
+	_, err = io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, 1*KiB, len(r.block))
+
+	assert.Panics(t, func() {
+		Encode(nil, nil, Options{BlockSize: 100})
+	})
+}
+
+func TestDecodeStream(t *testing.T) {
+	data := testLogData(100 * KiB)
+
+	var b Buf
+	var exp []byte
+
+	w := NewWriter(&b, 4*KiB, 256)
+
+	for i := 0; i+1000 <= len(data); i += 1000 {
+		p := data[i : i+1000]
+
+		switch i % 7000 {
+		case 0:
+			err := w.WriteBreak()
+			require.NoError(t, err)
+		case 3000:
+			w.Reset(&b)
+		case 5000:
+			p = make([]byte, 3000) // zero region
+		}
+
+		_, err := w.Write(p)
+		require.NoError(t, err)
+
+		exp = append(exp, p...)
+		b = append(b, Padding, Padding)
+	}
+
+	dec, err := Decode(nil, b)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(exp, dec))
+
+	dec, err = Decode(dec[:0], b[:len(b)-100])
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.True(t, bytes.HasPrefix(exp, dec))
+
+	_, err = Decode(nil, b[len(Magic)+2:])
+	assert.ErrorIs(t, err, ErrMissedMeta)
+
+	dec, err = DecodeLimit(dec[:0], b, 10000)
+	assert.ErrorIs(t, err, ErrOutputLimit)
+	assert.LessOrEqual(t, len(dec), 10000)
+	assert.True(t, bytes.HasPrefix(exp, dec))
+
+	dec, err = DecodeLimit(dec[:0], b, int64(len(exp)))
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(exp, dec))
+}
+
+func TestEncodeDecodeAllocs(t *testing.T) {
+	data := testLogData(64 * KiB)
+	opts := DefaultOptions()
+
+	enc := Encode(nil, data, opts)
+	dec := make([]byte, 0, len(data))
+
+	allocs := testing.AllocsPerRun(10, func() {
+		enc = Encode(enc[:0], data, opts)
+	})
+
+	if !raceEnabled {
+		assert.Zero(t, allocs, "encode")
+	}
+
+	allocs = testing.AllocsPerRun(10, func() {
+		dec, _ = Decode(dec[:0], enc)
+	})
+
+	assert.Zero(t, allocs, "decode")
+}
+
+func BenchmarkEncodeDecode(b *testing.B) {
+	data := testLogData(1 * MiB)
+	opts := DefaultOptions()
+
+	enc := Encode(nil, data, opts)
+	dec := make([]byte, 0, len(data))
+
+	b.Run("Encode", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+
+		for i := 0; i < b.N; i++ {
+			enc = Encode(enc[:0], data, opts)
+		}
+	})
+
+	b.Run("Decode", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+
+		for i := 0; i < b.N; i++ {
+			dec, _ = Decode(dec[:0], enc)
+		}
+	})
+}
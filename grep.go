@@ -0,0 +1,452 @@
+package eazy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+)
+
+type (
+	// Matcher selects lines for Grep.
+	// *regexp.Regexp implements it.
+	Matcher interface {
+		Match(line []byte) bool
+	}
+
+	// Fixed is a Matcher of lines containing the fixed string.
+	Fixed []byte
+
+	// Match is a line found by Grep.
+	Match struct {
+		// Line is the matching line without the trailing newline.
+		// It's only valid until the callback returns.
+		Line []byte
+
+		// Out is the decompressed offset of the line.
+		Out int64
+
+		// In is the compressed offset of the stream header
+		// the line is decoded after, and InOut is the decompressed offset of it.
+		// Decoding can be started at In without any saved state,
+		// the line is Out-InOut bytes after that.
+		In, InOut int64
+	}
+
+	// GrepOptions configures Grep and GrepIndexed.
+	GrepOptions struct {
+		Options
+
+		// From and To limit decompressed range of lines to search.
+		// Lines starting before From or at To and after are skipped.
+		// Zero To means no limit.
+		From, To int64
+
+		// Workers is the number of goroutines GrepIndexed searches segments on.
+		// Default is runtime.GOMAXPROCS(0).
+		Workers int
+	}
+
+	// grepper splits decoded data into lines and matches them.
+	grepper struct {
+		m        Matcher
+		from, to int64
+		f        func(Match) error
+
+		// head makes the first line saved instead of matched,
+		// for segments started in the middle of a line.
+		head     bool
+		headLine []byte
+
+		// skip drops segment heads until one is complete,
+		// they are the rest of a line started in a skipped segment.
+		skip bool
+
+		line Match // current line, Line is the buffer
+		open bool  // line is started
+	}
+
+	// grepSegment is a GrepIndexed segment search result.
+	grepSegment struct {
+		matches []Match // Line is nil, lines are in buf
+		ends    []int
+		buf     []byte
+
+		head     []byte
+		headDone bool // newline is found, head is complete
+
+		tail Match // the last line without newline, if open
+		open bool
+
+		err  error
+		done chan struct{}
+	}
+)
+
+var errGrepDone = errors.New("grep done")
+
+// Match implements Matcher.
+func (s Fixed) Match(line []byte) bool {
+	return bytes.Contains(line, s)
+}
+
+// Grep decodes compressed stream from r and calls f for each line m matches.
+// The last line may have no trailing newline.
+// Grep stops and returns the error if f returns one.
+func Grep(r io.Reader, m Matcher, opts GrepOptions, f func(Match) error) error {
+	d, err := NewReaderOptions(r, opts.Options)
+	if err != nil {
+		return err
+	}
+
+	defer d.Release()
+
+	g := grepper{m: m, from: opts.From, to: opts.To, f: f}
+
+	for {
+		p, err := d.Next()
+		if errors.Is(err, ErrBreak) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = g.write(p, Match{Out: d.out - int64(len(p)), In: d.stream, InOut: d.streamOut})
+		if err != nil {
+			return grepDone(err)
+		}
+	}
+
+	return grepDone(g.flush())
+}
+
+// GrepIndexed is Grep over an indexed file.
+// Segments outside of opts From and To range are skipped without decoding,
+// the rest are searched on opts.Workers goroutines.
+// f is called in order from the calling goroutine.
+func GrepIndexed(file *IndexedFile, m Matcher, opts GrepOptions, f func(Match) error) (err error) {
+	x := file.Index
+
+	if len(x.Entries) == 0 {
+		return Grep(io.NewSectionReader(file.f, 0, x.Size), m, opts, f)
+	}
+
+	_, err = opts.Options.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+
+	first, last := x.Find(opts.From), len(x.Entries)-1
+	if first < 0 {
+		first = 0
+	}
+
+	if opts.To != 0 {
+		last = x.Find(opts.To - 1)
+	}
+
+	if last < first {
+		return nil
+	}
+
+	head := first != 0
+
+	if head {
+		// the first segment starts with a complete line if the previous one ends with newline
+		var nl [1]byte
+
+		_, err = file.ReadAt(nl[:], x.Entries[first].Out-1)
+		if err != nil {
+			return err
+		}
+
+		head = nl[0] != '\n'
+	}
+
+	segs := make([]*grepSegment, last-first+1)
+	for i := range segs {
+		segs[i] = &grepSegment{done: make(chan struct{})}
+	}
+
+	jobs := make(chan int)
+	window := make(chan struct{}, 2*opts.Workers)
+	stop := make(chan struct{})
+
+	defer close(stop)
+
+	for i := 0; i < opts.Workers; i++ {
+		go func() {
+			for k := range jobs {
+				file.searchSegment(first+k, k != 0 || head, m, opts, segs[k])
+				close(segs[k].done)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+
+		for k := range segs {
+			select {
+			case window <- struct{}{}:
+			case <-stop:
+				return
+			}
+
+			select {
+			case jobs <- k:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	g := grepper{m: m, from: opts.From, to: opts.To, f: f, skip: head}
+
+	for k, s := range segs {
+		<-s.done
+		<-window
+
+		err = g.segment(s, x.Entries[first+k])
+		if err != nil {
+			return grepDone(err)
+		}
+	}
+
+	// the last line started before To may continue in the next segments
+	if g.open && last+1 < len(x.Entries) {
+		err = file.finishLine(&g, last+1, opts)
+		if err != nil {
+			return grepDone(err)
+		}
+	}
+
+	return grepDone(g.flush())
+}
+
+// finishLine decodes segments starting with the i-th one until the open line ends.
+func (f *IndexedFile) finishLine(g *grepper, i int, opts GrepOptions) error {
+	in, _, out, _ := f.Index.Segment(i)
+
+	d, err := NewReaderOptions(io.NewSectionReader(f.f, in, f.Index.Size-in), opts.Options)
+	if err != nil {
+		return err
+	}
+
+	defer d.Release()
+
+	for g.open {
+		p, err := d.Next()
+		if errors.Is(err, ErrBreak) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		pos := Match{Out: out + d.out - int64(len(p))}
+
+		// only the open line is written
+		if j := bytes.IndexByte(p, '\n'); j >= 0 {
+			p = p[:j+1]
+		}
+
+		err = g.write(p, pos)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// searchSegment searches the i-th segment and saves results to s.
+func (f *IndexedFile) searchSegment(i int, head bool, m Matcher, opts GrepOptions, s *grepSegment) {
+	in, end, out, _ := f.Index.Segment(i)
+
+	d, err := NewReaderOptions(io.NewSectionReader(f.f, in, end-in), opts.Options)
+	if err != nil {
+		s.err = err
+		return
+	}
+
+	defer d.Release()
+
+	g := grepper{m: m, from: opts.From, to: opts.To, head: head}
+
+	g.f = func(m Match) error {
+		s.buf = append(s.buf, m.Line...)
+		s.ends = append(s.ends, len(s.buf))
+
+		m.Line = nil
+		s.matches = append(s.matches, m)
+
+		return nil
+	}
+
+	for {
+		p, err := d.Next()
+		if errors.Is(err, ErrBreak) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			s.err = err
+			return
+		}
+
+		err = g.write(p, Match{Out: out + d.out - int64(len(p)), In: in + d.stream, InOut: out + d.streamOut})
+		if errors.Is(err, errGrepDone) {
+			break
+		}
+		if err != nil {
+			s.err = err
+			return
+		}
+	}
+
+	s.tail, s.open = g.line, g.open
+
+	if !head {
+		return
+	}
+
+	s.head, s.headDone = g.headLine, !g.head
+
+	if !s.headDone {
+		s.head, s.open = g.line.Line, false
+	}
+}
+
+// segment reports segment s results.
+func (g *grepper) segment(s *grepSegment, e IndexEntry) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	if g.skip {
+		g.skip = !s.headDone
+	} else if s.head != nil || s.headDone {
+		if !g.open {
+			g.line = Match{Line: g.line.Line[:0], Out: e.Out, In: e.In, InOut: e.Out}
+			g.open = true
+		}
+
+		g.line.Line = append(g.line.Line, s.head...)
+
+		if s.headDone {
+			err := g.endLine(g.line)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	st := 0
+
+	for i, m := range s.matches {
+		m.Line = s.buf[st:s.ends[i]]
+		st = s.ends[i]
+
+		err := g.f(m)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.open {
+		g.line = Match{Line: append(g.line.Line[:0], s.tail.Line...), Out: s.tail.Out, In: s.tail.In, InOut: s.tail.InOut}
+		g.open = true
+	}
+
+	return nil
+}
+
+// write processes decoded data p.
+// pos is p position: Out is p offset and In and InOut is the current stream.
+func (g *grepper) write(p []byte, pos Match) error {
+	for len(p) != 0 {
+		if !g.open {
+			pos.Line = g.line.Line[:0]
+			g.line = pos
+			g.open = true
+		}
+
+		j := bytes.IndexByte(p, '\n')
+		if j < 0 {
+			g.line.Line = append(g.line.Line, p...)
+			return nil
+		}
+
+		l := g.line
+
+		if len(l.Line) == 0 {
+			l.Line = p[:j] // whole line is in p, don't copy
+		} else {
+			g.line.Line = append(g.line.Line, p[:j]...)
+			l.Line = g.line.Line
+		}
+
+		err := g.endLine(l)
+		if err != nil {
+			return err
+		}
+
+		p = p[j+1:]
+		pos.Out += int64(j) + 1
+	}
+
+	return nil
+}
+
+// flush processes the last line without trailing newline.
+func (g *grepper) flush() error {
+	if !g.open {
+		return nil
+	}
+
+	return g.endLine(g.line)
+}
+
+// endLine matches the line l and closes the current one.
+func (g *grepper) endLine(l Match) error {
+	g.open = false
+
+	if g.head {
+		g.head = false
+		g.headLine = append([]byte{}, l.Line...)
+
+		return nil
+	}
+
+	if g.to != 0 && l.Out >= g.to {
+		return errGrepDone
+	}
+
+	if l.Out < g.from || !g.m.Match(l.Line) {
+		return nil
+	}
+
+	return g.f(l)
+}
+
+func grepDone(err error) error {
+	if errors.Is(err, errGrepDone) {
+		return nil
+	}
+
+	return err
+}
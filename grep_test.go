@@ -0,0 +1,283 @@
+package eazy
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrep(t *testing.T) {
+	data := testLogData(200 * KiB)
+
+	var b Buf
+
+	w := NewWriter(&b, 16*KiB, 256)
+
+	for i, j := 0, 0; i < len(data); i, j = i+3000, j+1 {
+		end := i + 3000
+		if end > len(data) {
+			end = len(data)
+		}
+
+		switch j % 5 {
+		case 2:
+			w.Reset(&b)
+		case 4:
+			err := w.WriteBreak()
+			require.NoError(t, err)
+		}
+
+		_, err := w.Write(data[i:end])
+		require.NoError(t, err)
+	}
+
+	m := regexp.MustCompile(`error .* status=500`)
+
+	exp := grepPlain(data, m, 0, 0)
+	require.NotEmpty(t, exp)
+
+	var got []Match
+
+	err := Grep(bytes.NewReader(b), m, GrepOptions{}, func(m Match) error {
+		m.Line = append([]byte{}, m.Line...)
+		got = append(got, m)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, len(exp), len(got))
+
+	for i, m := range got {
+		assert.Equal(t, exp[i].Out, m.Out)
+		assert.Equal(t, string(exp[i].Line), string(m.Line))
+
+		// decoding can start at In
+		dec, err := Decode(nil, b[m.In:])
+		require.NoError(t, err)
+
+		off := m.Out - m.InOut
+		assert.Equal(t, string(m.Line), string(dec[off:off+int64(len(m.Line))]))
+	}
+
+	assert.NotZero(t, got[len(got)-1].In)
+
+	// range
+
+	from, to := int64(50*KiB), int64(120*KiB)
+
+	got = got[:0]
+
+	err = Grep(bytes.NewReader(b), Fixed("status=500"), GrepOptions{From: from, To: to}, func(m Match) error {
+		got = append(got, m)
+		return nil
+	})
+	require.NoError(t, err)
+
+	exp = grepPlain(data, Fixed("status=500"), from, to)
+	require.Equal(t, len(exp), len(got))
+
+	for i, m := range got {
+		assert.Equal(t, exp[i].Out, m.Out)
+	}
+
+	// no trailing newline
+
+	err = Grep(bytes.NewReader(Encode(nil, []byte("a\nb\nlast"), DefaultOptions())), Fixed("last"), GrepOptions{}, func(m Match) error {
+		assert.Equal(t, "last", string(m.Line))
+		assert.Equal(t, int64(4), m.Out)
+
+		return io.ErrClosedPipe
+	})
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestGrepIndexed(t *testing.T) {
+	data := testLogData(1 << 20)
+
+	var b Buf
+
+	opts := ParallelOptions{
+		Options:     DefaultOptions(),
+		SegmentSize: 10000, // not aligned to lines
+	}
+
+	_, err := CompressParallel(&b, bytes.NewReader(data), opts)
+	require.NoError(t, err)
+
+	name := filepath.Join(t.TempDir(), "log.ez")
+
+	err = os.WriteFile(name, b, 0o644)
+	require.NoError(t, err)
+
+	x, err := BuildIndex(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	err = WriteIndexFile(name+IndexExt, x)
+	require.NoError(t, err)
+
+	f, err := OpenIndexed(name)
+	require.NoError(t, err)
+
+	defer func() {
+		err := f.Close()
+		assert.NoError(t, err)
+	}()
+
+	m := regexp.MustCompile(`error .*orders.* status=5`)
+
+	for _, tc := range []struct{ from, to int64 }{
+		{0, 0},
+		{0, 10000},
+		{10000, 20000},
+		{10003, 300000},
+		{123456, 0},
+	} {
+		var exp, got []Match
+
+		gopts := GrepOptions{From: tc.from, To: tc.to, Workers: 3}
+
+		collect := func(res *[]Match) func(Match) error {
+			return func(m Match) error {
+				m.Line = append([]byte{}, m.Line...)
+				*res = append(*res, m)
+
+				return nil
+			}
+		}
+
+		err = Grep(bytes.NewReader(b), m, gopts, collect(&exp))
+		require.NoError(t, err)
+
+		err = GrepIndexed(f, m, gopts, collect(&got))
+		require.NoError(t, err)
+
+		assert.Equal(t, exp, got, "range %d - %d", tc.from, tc.to)
+		assert.Equal(t, len(grepPlain(data, m, tc.from, tc.to)), len(got))
+	}
+
+	// lines split between segments
+
+	split := 0
+
+	for _, e := range x.Entries[1:] {
+		if data[e.Out-1] != '\n' {
+			split++
+		}
+	}
+
+	require.NotZero(t, split)
+
+	var got []Match
+
+	err = GrepIndexed(f, Fixed(""), GrepOptions{}, func(m Match) error {
+		got = append(got, Match{Out: m.Out, Line: append([]byte{}, m.Line...)})
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, grepPlain(data, Fixed(""), 0, 0), got)
+}
+
+func TestGrepIndexedLongLines(t *testing.T) {
+	log := testLogData(256 * KiB)
+
+	var data []byte
+
+	// lines longer than a segment between short ones
+	for i, st := 0, 0; st < len(log); i++ {
+		n := 100
+		if i%3 == 0 {
+			n = 25000
+		}
+
+		end := st + n
+		if end > len(log) {
+			end = len(log)
+		}
+
+		data = append(data, bytes.ReplaceAll(log[st:end], []byte("\n"), []byte(" "))...)
+		data = append(data, '\n')
+
+		st = end
+	}
+
+	var b Buf
+
+	_, err := CompressParallel(&b, bytes.NewReader(data), ParallelOptions{
+		Options:     DefaultOptions(),
+		SegmentSize: 4000,
+	})
+	require.NoError(t, err)
+
+	name := filepath.Join(t.TempDir(), "log.ez")
+
+	err = os.WriteFile(name, b, 0o644)
+	require.NoError(t, err)
+
+	x, err := BuildIndex(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Greater(t, len(x.Entries), 20)
+
+	err = WriteIndexFile(name+IndexExt, x)
+	require.NoError(t, err)
+
+	f, err := OpenIndexed(name)
+	require.NoError(t, err)
+
+	defer func() {
+		err := f.Close()
+		assert.NoError(t, err)
+	}()
+
+	collect := func(res *[]Match) func(Match) error {
+		return func(m Match) error {
+			*res = append(*res, Match{Out: m.Out, Line: append([]byte{}, m.Line...)})
+			return nil
+		}
+	}
+
+	for _, tc := range []struct{ from, to int64 }{
+		{0, 0},
+		{0, 6621},      // To in the middle of a long line
+		{7876, 0},      // From in the middle of a long line
+		{7876, 60000},  // both
+		{25000, 25200}, // short lines only
+		{30000, 40000}, // a long line only
+	} {
+		var exp, got []Match
+
+		gopts := GrepOptions{From: tc.from, To: tc.to, Workers: 3}
+
+		err = Grep(bytes.NewReader(b), Fixed(""), gopts, collect(&exp))
+		require.NoError(t, err)
+
+		err = GrepIndexed(f, Fixed(""), gopts, collect(&got))
+		require.NoError(t, err)
+
+		assert.Equal(t, grepPlain(data, Fixed(""), tc.from, tc.to), exp, "range %d - %d", tc.from, tc.to)
+		assert.Equal(t, exp, got, "range %d - %d", tc.from, tc.to)
+	}
+}
+
+func grepPlain(data []byte, m Matcher, from, to int64) (res []Match) {
+	var out int64
+
+	for _, l := range bytes.SplitAfter(data, []byte("\n")) {
+		line := bytes.TrimSuffix(l, []byte("\n"))
+
+		if len(l) != 0 && out >= from && (to == 0 || out < to) && m.Match(line) {
+			res = append(res, Match{Out: out, Line: line})
+		}
+
+		out += int64(len(l))
+	}
+
+	return res
+}
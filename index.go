@@ -0,0 +1,374 @@
+package eazy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+)
+
+type (
+	// Index is a seek index of a compressed file.
+	// It lists stream headers, which are the points decoding can start from
+	// without any saved state, so it's small and can be built
+	// for immutable files after the fact.
+	// It's saved as a sidecar file with IndexExt extension.
+	//
+	// Files written by CompressParallel or by a Writer Reset periodically
+	// have a header per segment.
+	Index struct {
+		Entries []IndexEntry
+
+		Size    int64 // compressed file size
+		OutSize int64 // decompressed size
+	}
+
+	// IndexEntry is a stream header position.
+	IndexEntry struct {
+		In  int64 // compressed offset
+		Out int64 // decompressed offset, Break markers don't count
+
+		// Breaks is the number of Break markers before the entry.
+		Breaks int64
+
+		// Time is an optional timestamp, unix nanoseconds.
+		// It's not known to BuildIndex and can be set by the caller,
+		// for example by decoding the first record of each segment.
+		Time int64
+	}
+)
+
+// IndexExt is the index sidecar file extension.
+// Index for file "name.ez" is stored in "name.ez.ezi".
+const IndexExt = ".ezi"
+
+const indexMagic = "ezi\x00"
+
+// ErrBadIndex is returned when index file is corrupted.
+var ErrBadIndex = errors.New("bad index")
+
+// BuildIndex scans the compressed stream without decoding it
+// and records stream headers positions.
+func BuildIndex(r io.Reader) (x *Index, err error) {
+	s := Reader{
+		Reader:     r,
+		BufferSize: DefaultBufferSize,
+	}
+
+	x = &Index{}
+
+	var out, breaks, skip int64
+	hdr := int64(-1)
+
+	for {
+		if skip != 0 {
+			n := int64(len(s.b) - s.i)
+			if n > skip {
+				n = skip
+			}
+
+			s.i += int(n)
+			skip -= n
+
+			if skip == 0 {
+				continue
+			}
+		}
+
+		e, i, err := parseElement(s.d, s.b, s.i)
+		if errors.Is(err, ErrShortBuffer) {
+			// trailing padding is not an element, parseElement returns before it
+			for skip == 0 && s.i < len(s.b) && s.b[s.i] == Padding {
+				s.i++
+			}
+
+			err = s.more()
+			if errors.Is(err, io.EOF) && (skip != 0 || s.i < len(s.b)) {
+				cerr := &CorruptError{Err: io.ErrUnexpectedEOF, In: s.boff + int64(s.i), Out: out, Kind: tagKind(s.b, s.i)}
+				if skip != 0 {
+					cerr.Kind = "literal"
+				}
+
+				err = cerr
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+		if err != nil {
+			return nil, &CorruptError{Err: err, In: s.boff + int64(s.i), Out: out, Kind: tagKind(s.b, s.i)}
+		}
+
+		st := s.boff + int64(e.st)
+		s.i = i
+
+		switch {
+		case e.tag == 'l':
+			out += int64(e.l)
+			skip = int64(e.l)
+		case e.tag == 'c':
+			out += int64(e.l)
+		case e.off == MetaMagic || e.off == MetaVer:
+			if hdr < 0 {
+				hdr = st
+			}
+
+			continue
+		case e.off == MetaReset:
+			if hdr < 0 {
+				hdr = st
+			}
+
+			x.Entries = append(x.Entries, IndexEntry{In: hdr, Out: out, Breaks: breaks})
+		case e.off == MetaBreak:
+			breaks++
+		}
+
+		hdr = -1
+	}
+
+	x.Size = s.boff + int64(s.i)
+	x.OutSize = out
+
+	return x, nil
+}
+
+// Find returns the index of the last entry starting at or before decompressed offset out.
+// It returns -1 if there is no such entry.
+func (x *Index) Find(out int64) int {
+	return sort.Search(len(x.Entries), func(i int) bool {
+		return x.Entries[i].Out > out
+	}) - 1
+}
+
+// FindTime returns the index of the last entry with Time at or before ts.
+// Entries must have Time set in ascending order.
+// It returns -1 if there is no such entry.
+func (x *Index) FindTime(ts int64) int {
+	return sort.Search(len(x.Entries), func(i int) bool {
+		return x.Entries[i].Time > ts
+	}) - 1
+}
+
+// Segment returns compressed and decompressed ranges of the i-th segment.
+func (x *Index) Segment(i int) (in, inEnd, out, outEnd int64) {
+	e := x.Entries[i]
+
+	inEnd, outEnd = x.Size, x.OutSize
+
+	if i+1 < len(x.Entries) {
+		inEnd, outEnd = x.Entries[i+1].In, x.Entries[i+1].Out
+	}
+
+	return e.In, inEnd, e.Out, outEnd
+}
+
+// Checkpoints converts Index into Checkpoints to be used with NewReaderAt.
+// Stream headers need no window, so the result takes as little memory as Index.
+func (x *Index) Checkpoints() *Checkpoints {
+	cps := &Checkpoints{
+		List: make([]Checkpoint, 0, len(x.Entries)+1),
+		Size: x.OutSize,
+	}
+
+	if len(x.Entries) == 0 || x.Entries[0].Out != 0 {
+		cps.List = append(cps.List, Checkpoint{})
+	}
+
+	for _, e := range x.Entries {
+		cps.List = append(cps.List, Checkpoint{In: e.In, Out: e.Out})
+	}
+
+	return cps
+}
+
+// MarshalBinary encodes Index in the sidecar file format.
+//
+// Format is the magic "ezi\x00", uvarint version,
+// uvarint Size, OutSize and number of entries,
+// then entries as deltas from the previous one:
+// uvarint In, Out, Breaks and varint Time,
+// followed by CRC32 (IEEE) of all the previous bytes in little endian.
+func (x *Index) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 16+len(x.Entries)*8)
+
+	b = append(b, indexMagic...)
+	b = binary.AppendUvarint(b, 0)
+	b = binary.AppendUvarint(b, uint64(x.Size))
+	b = binary.AppendUvarint(b, uint64(x.OutSize))
+	b = binary.AppendUvarint(b, uint64(len(x.Entries)))
+
+	var prev IndexEntry
+
+	for _, e := range x.Entries {
+		if e.In < prev.In || e.Out < prev.Out || e.Breaks < prev.Breaks {
+			return nil, fmt.Errorf("%w: entries are not sorted", ErrBadIndex)
+		}
+
+		b = binary.AppendUvarint(b, uint64(e.In-prev.In))
+		b = binary.AppendUvarint(b, uint64(e.Out-prev.Out))
+		b = binary.AppendUvarint(b, uint64(e.Breaks-prev.Breaks))
+		b = binary.AppendVarint(b, e.Time-prev.Time)
+
+		prev = e
+	}
+
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+
+	return b, nil
+}
+
+// UnmarshalBinary decodes Index encoded by MarshalBinary.
+func (x *Index) UnmarshalBinary(b []byte) (err error) {
+	if len(b) < len(indexMagic)+4 || string(b[:len(indexMagic)]) != indexMagic {
+		return fmt.Errorf("%w: no magic", ErrBadIndex)
+	}
+
+	end := len(b) - 4
+
+	if crc32.ChecksumIEEE(b[:end]) != binary.LittleEndian.Uint32(b[end:]) {
+		return fmt.Errorf("%w: checksum mismatch", ErrBadIndex)
+	}
+
+	b = b[len(indexMagic):end]
+
+	var bad bool
+
+	uvarint := func() int64 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v > 1<<62 {
+			bad = true
+			return 0
+		}
+
+		b = b[n:]
+
+		return int64(v)
+	}
+
+	if ver := uvarint(); ver != 0 && !bad {
+		return fmt.Errorf("%w: index version %v", ErrUnsupportedVersion, ver)
+	}
+
+	x.Size = uvarint()
+	x.OutSize = uvarint()
+	n := uvarint()
+
+	if bad || n > int64(len(b)) {
+		return fmt.Errorf("%w: header", ErrBadIndex)
+	}
+
+	x.Entries = make([]IndexEntry, 0, n)
+
+	var e IndexEntry
+
+	for i := int64(0); i < n; i++ {
+		e.In += uvarint()
+		e.Out += uvarint()
+		e.Breaks += uvarint()
+
+		t, m := binary.Varint(b)
+		if m <= 0 || bad {
+			return fmt.Errorf("%w: entry %d", ErrBadIndex, i)
+		}
+
+		b = b[m:]
+		e.Time += t
+
+		x.Entries = append(x.Entries, e)
+	}
+
+	if len(b) != 0 {
+		return fmt.Errorf("%w: trailing data", ErrBadIndex)
+	}
+
+	return nil
+}
+
+// WriteIndexFile writes Index x to the file name.
+func WriteIndexFile(name string, x *Index) error {
+	b, err := x.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(name, b, 0o644)
+}
+
+// ReadIndexFile reads Index from the file name.
+func ReadIndexFile(name string) (*Index, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &Index{}
+
+	err = x.UnmarshalBinary(b)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", name, err)
+	}
+
+	return x, nil
+}
+
+// IndexedFile is a compressed file opened along with its index sidecar.
+// It embeds ReaderAt to read decompressed data at random offsets.
+type IndexedFile struct {
+	*ReaderAt
+
+	Index *Index
+
+	f *os.File
+}
+
+// OpenIndexed opens compressed file name and its name+IndexExt index.
+// Index is considered stale and ErrBadIndex is returned
+// if its Size doesn't match the file size.
+func OpenIndexed(name string) (_ *IndexedFile, err error) {
+	x, err := ReadIndexFile(name + IndexExt)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	inf, err := f.Stat()
+	if err == nil && inf.Size() != x.Size {
+		err = fmt.Errorf("%v: %w: index is for size %d, file size %d", name+IndexExt, ErrBadIndex, x.Size, inf.Size())
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &IndexedFile{
+		ReaderAt: NewReaderAt(f, x.Checkpoints()),
+		Index:    x,
+		f:        f,
+	}, nil
+}
+
+// SegmentReader returns a Reader decoding the i-th segment.
+// Segments are independent, so they can be decoded concurrently.
+func (f *IndexedFile) SegmentReader(i int) *Reader {
+	in, end, _, _ := f.Index.Segment(i)
+
+	return NewReader(io.NewSectionReader(f.f, in, end-in))
+}
+
+// Close closes the file.
+func (f *IndexedFile) Close() error {
+	return f.f.Close()
+}
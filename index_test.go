@@ -0,0 +1,142 @@
+package eazy
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	data := testLogData(1 << 20)
+
+	var b Buf
+
+	opts := ParallelOptions{
+		Options:     DefaultOptions(),
+		SegmentSize: 64 * KiB,
+		Align:       512,
+	}
+
+	opts.BlockSize = 16 * KiB
+
+	_, err := CompressParallel(&b, bytes.NewReader(data), opts)
+	require.NoError(t, err)
+
+	x, err := BuildIndex(&BufReader{Buf: b})
+	require.NoError(t, err)
+
+	assert.Len(t, x.Entries, len(data)/opts.SegmentSize)
+	assert.Equal(t, int64(len(b)), x.Size)
+	assert.Equal(t, int64(len(data)), x.OutSize)
+
+	for i, e := range x.Entries {
+		assert.Equal(t, int64(i*opts.SegmentSize), e.Out)
+		assert.Equal(t, Magic, string(b[e.In:e.In+int64(len(Magic))]))
+
+		x.Entries[i].Time = int64(1000 * i)
+	}
+
+	assert.Equal(t, 3, x.Find(int64(3*opts.SegmentSize+10)))
+	assert.Equal(t, 3, x.FindTime(3500))
+	assert.Equal(t, -1, x.FindTime(-1))
+
+	enc, err := x.MarshalBinary()
+	require.NoError(t, err)
+
+	t.Logf("index size %d  entries %d", len(enc), len(x.Entries))
+
+	var x2 Index
+
+	err = x2.UnmarshalBinary(enc)
+	require.NoError(t, err)
+	assert.Equal(t, x, &x2)
+
+	enc[len(enc)/2]++
+
+	err = x2.UnmarshalBinary(enc)
+	assert.ErrorIs(t, err, ErrBadIndex)
+
+	// files
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "data.ez")
+
+	err = os.WriteFile(name, b, 0o644)
+	require.NoError(t, err)
+
+	err = WriteIndexFile(name+IndexExt, x)
+	require.NoError(t, err)
+
+	f, err := OpenIndexed(name)
+	require.NoError(t, err)
+
+	defer func() {
+		err := f.Close()
+		assert.NoError(t, err)
+	}()
+
+	rnd := rand.New(rand.NewSource(0))
+	p := make([]byte, 3000)
+
+	for i := 0; i < 20; i++ {
+		off := rnd.Int63n(int64(len(data) - len(p)))
+
+		n, err := f.ReadAt(p, off)
+		assert.NoError(t, err)
+		assert.True(t, bytes.Equal(data[off:off+int64(n)], p[:n]))
+	}
+
+	seg, err := io.ReadAll(f.SegmentReader(5))
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(data[5*opts.SegmentSize:6*opts.SegmentSize], seg))
+
+	// trailing padding
+
+	padded := append(b[:len(b):len(b)], make([]byte, 100)...)
+
+	x2p, err := BuildIndex(&BufReader{Buf: padded})
+	require.NoError(t, err)
+	assert.Len(t, x2p.Entries, len(x.Entries))
+	assert.Equal(t, x.OutSize, x2p.OutSize)
+	assert.Equal(t, int64(len(padded)), x2p.Size)
+
+	// stale index
+
+	err = os.WriteFile(name, padded, 0o644)
+	require.NoError(t, err)
+
+	_, err = OpenIndexed(name)
+	assert.ErrorIs(t, err, ErrBadIndex)
+}
+
+func TestIndexBreaks(t *testing.T) {
+	var b Buf
+
+	w := NewWriter(&b, 1024, 32)
+
+	_, _ = w.Write([]byte("first"))
+	_ = w.WriteBreak()
+	_, _ = w.Write([]byte("second"))
+	_ = w.WriteBreak()
+
+	w.Reset(&b)
+
+	_, _ = w.Write([]byte("third"))
+
+	x, err := BuildIndex(&BufReader{Buf: b})
+	require.NoError(t, err)
+
+	assert.Equal(t, []IndexEntry{
+		{In: 0, Out: 0},
+		{In: int64(bytes.LastIndex(b, []byte(Magic))), Out: 11, Breaks: 2},
+	}, x.Entries)
+
+	_, err = BuildIndex(&BufReader{Buf: b[:len(b)-2]})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package eazy
+
+const raceEnabled = false
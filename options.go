@@ -0,0 +1,202 @@
+package eazy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+type (
+	// Options is a Writer and Reader configuration.
+	// Unlike NewWriter it's validated and errors are returned instead of panics,
+	// so it's safe to fill it from a user provided config.
+	//
+	// Zero values mean defaults, so a missing config key keeps the default.
+	Options struct {
+		// BlockSize is a Writer window size.
+		// It must be a power of two in [32, 1<<31]. Default is 1 MiB.
+		// It must not exceed BlockSizeLimit, so that a Reader configured
+		// by the same Options is able to read the stream.
+		BlockSize int
+
+		// HashTableSize is a Writer hash table size.
+		// It must be a power of two not less than 4. Default is 1024.
+		HashTableSize int
+
+		// FlushThreshold is the same as Writer.FlushThreshold.
+		// It must be not less than -1.
+		FlushThreshold int
+
+		// NoMagic is the inverted Writer.AppendMagic.
+		// Magic is appended by default.
+		NoMagic bool
+
+		// BlockSizeLimit is the same as Reader.BlockSizeLimit.
+		// Default is 16 MiB, -1 means no limit.
+		BlockSizeLimit int
+
+		// BufferSize is the same as Reader.BufferSize.
+		// Default is 64 KiB.
+		BufferSize int
+
+		// BufferSizeLimit is the same as Reader.BufferSizeLimit.
+		// Default is 1 MiB, -1 means no limit.
+		BufferSizeLimit int
+
+		// OutputLimit is the same as Reader.OutputLimit.
+		OutputLimit int64
+
+		// RatioLimit is the same as Reader.RatioLimit.
+		RatioLimit float64
+
+		// RequireMagic is the same as Reader.RequireMagic.
+		RequireMagic bool
+
+		// SkipUnsupportedMeta is the same as Reader.SkipUnsupportedMeta.
+		SkipUnsupportedMeta bool
+	}
+)
+
+// Default options values.
+const (
+	DefaultBlockSize       = 1 * MiB
+	DefaultHashTableSize   = 1024
+	DefaultBlockSizeLimit  = 16 * MiB
+	DefaultBufferSize      = 64 * KiB
+	DefaultBufferSizeLimit = 1 * MiB
+	DefaultChunkSize       = 64 * KiB
+)
+
+var (
+	ErrBadBlockSize     = errors.New("block size must be a power of two (32 <= bs <= 1<<31)")
+	ErrBadHashTableSize = errors.New("hash table size must be a power of two (hs >= 4)")
+	ErrBadOption        = errors.New("bad option")
+)
+
+// DefaultOptions returns options NewWriter and NewReader use by default.
+func DefaultOptions() Options {
+	return Options{
+		BlockSize:       DefaultBlockSize,
+		HashTableSize:   DefaultHashTableSize,
+		BlockSizeLimit:  DefaultBlockSizeLimit,
+		BufferSize:      DefaultBufferSize,
+		BufferSizeLimit: DefaultBufferSizeLimit,
+	}
+}
+
+// NewWriterOptions creates new compressor writing to wr configured by opts.
+// It returns an error if options are not valid.
+func NewWriterOptions(wr io.Writer, opts Options) (*Writer, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Writer{
+		Writer:         wr,
+		AppendMagic:    !opts.NoMagic,
+		FlushThreshold: opts.FlushThreshold,
+		e: Encoder{
+			Ver: Version,
+		},
+	}
+
+	w.init(opts.BlockSize, opts.HashTableSize)
+
+	return w, nil
+}
+
+// NewReaderOptions creates new decompressor reading from r configured by opts.
+// It returns an error if options are not valid.
+// Writer only options are validated too, so the same Options
+// can be used for both sides.
+func NewReaderOptions(r io.Reader, opts Options) (*Reader, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.BlockSizeLimit < 0 {
+		opts.BlockSizeLimit = 0
+	}
+
+	if opts.BufferSizeLimit < 0 {
+		opts.BufferSizeLimit = 0
+	}
+
+	return &Reader{
+		Reader:              r,
+		BlockSizeLimit:      opts.BlockSizeLimit,
+		BufferSize:          opts.BufferSize,
+		BufferSizeLimit:     opts.BufferSizeLimit,
+		OutputLimit:         opts.OutputLimit,
+		RatioLimit:          opts.RatioLimit,
+		RequireMagic:        opts.RequireMagic,
+		SkipUnsupportedMeta: opts.SkipUnsupportedMeta,
+	}, nil
+}
+
+func (o Options) withDefaults() (Options, error) {
+	if o.BlockSize == 0 {
+		o.BlockSize = DefaultBlockSize
+	}
+
+	if o.HashTableSize == 0 {
+		o.HashTableSize = DefaultHashTableSize
+	}
+
+	if o.BlockSizeLimit == 0 {
+		o.BlockSizeLimit = DefaultBlockSizeLimit
+	}
+
+	if o.BufferSize == 0 {
+		o.BufferSize = DefaultBufferSize
+	}
+
+	if o.BufferSizeLimit == 0 {
+		o.BufferSizeLimit = DefaultBufferSizeLimit
+	}
+
+	if o.FlushThreshold < -1 {
+		return o, fmt.Errorf("%w: flush threshold: %v", ErrBadOption, o.FlushThreshold)
+	}
+
+	if o.BlockSizeLimit < -1 {
+		return o, fmt.Errorf("%w: block size limit: %v", ErrBadOption, o.BlockSizeLimit)
+	}
+
+	if o.BufferSize < 0 {
+		return o, fmt.Errorf("%w: buffer size: %v", ErrBadOption, o.BufferSize)
+	}
+
+	if o.BufferSizeLimit < -1 {
+		return o, fmt.Errorf("%w: buffer size limit: %v", ErrBadOption, o.BufferSizeLimit)
+	}
+
+	if o.OutputLimit < 0 || o.RatioLimit < 0 {
+		return o, fmt.Errorf("%w: output limit %v, ratio limit %v", ErrBadOption, o.OutputLimit, o.RatioLimit)
+	}
+
+	err := checkSizes(o.BlockSize, o.HashTableSize)
+	if err != nil {
+		return o, err
+	}
+
+	if o.BlockSizeLimit > 0 && o.BlockSize > o.BlockSizeLimit {
+		return o, fmt.Errorf("%w: block size %v is more than block size limit %v", ErrBadOption, o.BlockSize, o.BlockSizeLimit)
+	}
+
+	return o, nil
+}
+
+func checkSizes(bs, hs int) error {
+	if (bs-1)&bs != 0 || bs < 32 || bs > 1<<31 {
+		return fmt.Errorf("%w: %v", ErrBadBlockSize, bs)
+	}
+
+	if (hs-1)&hs != 0 || hs < 4 {
+		return fmt.Errorf("%w: %v", ErrBadHashTableSize, hs)
+	}
+
+	return nil
+}
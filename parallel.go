@@ -0,0 +1,817 @@
+package eazy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+type (
+	// ParallelOptions configures CompressParallel.
+	ParallelOptions struct {
+		Options
+
+		// SegmentSize is the uncompressed size of independently compressed segments.
+		// Bigger segments compress better as each one starts with an empty window.
+		// Default is 4 * BlockSize.
+		SegmentSize int
+
+		// Workers is the number of goroutines compressing segments.
+		// Default is runtime.GOMAXPROCS(0).
+		Workers int
+
+		// Align pads segments with Padding so that each one starts at a multiple of Align
+		// in the output stream. 0 disables padding.
+		Align int
+	}
+
+	segment struct {
+		in, out []byte
+		err     error
+		done    chan struct{}
+	}
+)
+
+// CompressParallel compresses data from r by segments on multiple goroutines
+// and writes them to w in order.
+// Each segment is a complete stream with its own header,
+// so the result is a concatenated stream which can be decoded by Reader.
+//
+// It returns the number of compressed bytes written including padding.
+func CompressParallel(w io.Writer, r io.Reader, opts ParallelOptions) (written int64, err error) {
+	opts, err = opts.withDefaults()
+	if err != nil {
+		return 0, err
+	}
+
+	jobs := make(chan *segment)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+
+		go compressSegments(jobs, opts.Options, &wg)
+	}
+
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	ring := make([]*segment, 2*opts.Workers)
+
+	for i := range ring {
+		ring[i] = &segment{done: make(chan struct{}, 1)}
+	}
+
+	var head, inflight int
+	eof := false
+
+	for !eof || inflight != 0 {
+		if !eof && inflight < len(ring) {
+			s := ring[(head+inflight)%len(ring)]
+
+			if cap(s.in) < opts.SegmentSize {
+				s.in = make([]byte, opts.SegmentSize)
+			}
+
+			n, err := io.ReadFull(r, s.in[:opts.SegmentSize])
+			s.in = s.in[:n]
+
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				eof = true
+			} else if err != nil {
+				return written, fmt.Errorf("read: %w", err)
+			}
+
+			if n != 0 {
+				inflight++
+				jobs <- s
+			}
+
+			continue
+		}
+
+		s := ring[head]
+		<-s.done
+
+		head = (head + 1) % len(ring)
+		inflight--
+
+		if s.err != nil {
+			return written, s.err
+		}
+
+		if opts.Align > 0 && written%int64(opts.Align) != 0 {
+			n, err := writePadding(w, opts.Align-int(written%int64(opts.Align)))
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+		}
+
+		n, err := w.Write(s.out)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+func compressSegments(jobs chan *segment, opts Options, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	w := NewWriter(nil, opts.BlockSize, opts.HashTableSize)
+	w.AppendMagic = !opts.NoMagic
+	w.FlushThreshold = -1
+
+	for s := range jobs {
+		w.Reset(nil)
+		w.b = s.out[:0] // compress directly into the segment output buffer
+
+		_, s.err = w.Write(s.in)
+		s.out = w.b
+
+		s.done <- struct{}{}
+	}
+}
+
+func writePadding(w io.Writer, n int) (written int, err error) {
+	for written < n {
+		end := n - written
+		if end > len(zeros) {
+			end = len(zeros)
+		}
+
+		m, err := w.Write(zeros[:end])
+		written += m
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+func (o ParallelOptions) withDefaults() (_ ParallelOptions, err error) {
+	o.Options, err = o.Options.withDefaults()
+	if err != nil {
+		return o, err
+	}
+
+	if o.SegmentSize == 0 {
+		o.SegmentSize = 4 * o.BlockSize
+	}
+
+	if o.Workers == 0 {
+		o.Workers = runtime.GOMAXPROCS(0)
+	}
+
+	if o.SegmentSize < 0 || o.Workers < 0 || o.Align < 0 {
+		return o, fmt.Errorf("%w: segment size %v, workers %v, align %v", ErrBadOption, o.SegmentSize, o.Workers, o.Align)
+	}
+
+	return o, nil
+}
+
+type (
+	// ParallelReader decodes a concatenated stream on multiple goroutines.
+	// Stream is split at MetaReset boundaries (stream headers),
+	// which are the only points decoding can start from,
+	// and segments are decoded independently.
+	// Decoded data is returned in order.
+	//
+	// Segments are buffered whole, so their size is bounded.
+	// A segment is at most BufferSizeLimit of compressed data,
+	// or 4 * max(SegmentSize, BlockSize) if there is no limit,
+	// and SegmentSize is reduced to half of that if it's bigger.
+	// If no stream header is found within that size,
+	// the rest of the stream is decoded sequentially as Reader does.
+	// A segment expanding to more than 16 times the max segment size
+	// is decoded sequentially too, as its output is not buffered then.
+	//
+	// OutputLimit and RatioLimit are checked over the whole output as Reader does.
+	//
+	// Break markers are skipped.
+	// Close must be called if the stream is not read till the end.
+	ParallelReader struct {
+		opts   ParallelOptions
+		max    int // max segment size
+		maxOut int // max segment output size
+
+		queue chan *decodeJob
+		free  chan *decodeJob
+		stop  chan struct{}
+		once  sync.Once
+
+		cur  *decodeJob
+		off  int
+		base int64 // cur output offset
+
+		seq    *Reader // sequential decoder
+		seqJob *decodeJob
+
+		out int64 // returned bytes
+		err error
+	}
+
+	decodeJob struct {
+		in, out []byte
+		boff    int64 // in offset in the stream
+		err     error
+		done    chan struct{}
+
+		// rest is the rest of the stream starting at boff to be decoded sequentially.
+		rest io.Reader
+
+		// overflow is set if the segment output is too big to buffer,
+		// it's decoded sequentially from in then.
+		overflow bool
+	}
+)
+
+// NewParallelReader creates ParallelReader reading from r.
+// Reader options are taken from opts.Options.
+// SegmentSize is the minimal compressed size of a segment,
+// consecutive short streams are decoded together up to that size.
+func NewParallelReader(r io.Reader, opts ParallelOptions) (*ParallelReader, error) {
+	pr, jobs, err := newParallelReader(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	go pr.split(r, jobs)
+
+	return pr, nil
+}
+
+// NewParallelReaderIndexed creates ParallelReader decoding the indexed file.
+// Segments are taken from the index instead of scanning the stream,
+// so they are read from the file concurrently.
+func NewParallelReaderIndexed(f *IndexedFile, opts ParallelOptions) (*ParallelReader, error) {
+	pr, jobs, err := newParallelReader(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	go pr.splitIndexed(f, jobs)
+
+	return pr, nil
+}
+
+func newParallelReader(opts ParallelOptions) (*ParallelReader, chan *decodeJob, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	max := opts.SegmentSize
+	if max < opts.BlockSize {
+		max = opts.BlockSize
+	}
+
+	max *= 4
+
+	if l := opts.BufferSizeLimit; l > 0 && l < max {
+		max = l
+	}
+
+	if opts.SegmentSize > max/2 {
+		opts.SegmentSize = max / 2
+	}
+
+	pr := &ParallelReader{
+		opts:   opts,
+		max:    max,
+		maxOut: 16 * max,
+		queue:  make(chan *decodeJob, opts.Workers),
+		free:   make(chan *decodeJob, 2*opts.Workers+1),
+		stop:   make(chan struct{}),
+	}
+
+	jobs := make(chan *decodeJob)
+
+	for i := 0; i < opts.Workers; i++ {
+		go pr.decodeSegments(jobs)
+	}
+
+	return pr, jobs, nil
+}
+
+// Read implements io.Reader.
+func (pr *ParallelReader) Read(p []byte) (n int, err error) {
+	if pr.err != nil {
+		return 0, pr.err
+	}
+
+	for n < len(p) {
+		if pr.seq != nil {
+			m, err := pr.readSequential(p[n:])
+			n += m
+
+			if err != nil {
+				pr.err = err
+				return n, err
+			}
+
+			continue
+		}
+
+		if pr.cur == nil {
+			if n != 0 && len(pr.queue) == 0 {
+				return n, nil
+			}
+
+			j, ok := <-pr.queue
+			if !ok {
+				pr.err = io.EOF
+				return n, pr.err
+			}
+
+			<-j.done
+
+			if j.rest != nil || j.overflow {
+				pr.err = pr.sequential(j)
+				if pr.err != nil {
+					return n, pr.err
+				}
+
+				continue
+			}
+
+			pr.cur, pr.off, pr.base = j, 0, pr.out
+		}
+
+		if pr.off < len(pr.cur.out) {
+			m, err := pr.limit(pr.cur, len(pr.cur.out)-pr.off)
+			if err != nil {
+				pr.err = err
+				return n, err
+			}
+
+			m = copy(p[n:], pr.cur.out[pr.off:pr.off+m])
+			n += m
+			pr.off += m
+			pr.out += int64(m)
+		}
+
+		if pr.off < len(pr.cur.out) {
+			break
+		}
+
+		if pr.cur.err != nil {
+			pr.err = pr.segmentError(pr.cur.err)
+			return n, pr.err
+		}
+
+		pr.recycle(pr.cur)
+		pr.cur = nil
+	}
+
+	return n, nil
+}
+
+// limit checks OutputLimit and RatioLimit over the whole output
+// and returns how much of j output can be returned.
+// Compressed size is taken at the segment end.
+func (pr *ParallelReader) limit(j *decodeJob, m int) (int, error) {
+	in := j.boff + int64(len(j.in))
+
+	if l := pr.opts.OutputLimit; l != 0 {
+		rem := l - pr.out
+		if rem <= 0 {
+			return 0, &LimitError{Err: ErrOutputLimit, In: in, Out: pr.out}
+		}
+
+		if int64(m) > rem {
+			m = int(rem)
+		}
+	}
+
+	if l := pr.opts.RatioLimit; l != 0 && pr.out >= ratioMinOut && float64(pr.out) > l*float64(in) {
+		return 0, &LimitError{Err: ErrRatioLimit, In: in, Out: pr.out}
+	}
+
+	return m, nil
+}
+
+// segmentError makes segment decoder error offsets absolute.
+// Segment decoder In is already absolute, Out is counted from the segment start.
+func (pr *ParallelReader) segmentError(err error) error {
+	var lerr *LimitError
+	var cerr *CorruptError
+
+	switch {
+	case errors.As(err, &lerr):
+		e := *lerr
+		e.Out += pr.base
+
+		return &e
+	case errors.As(err, &cerr):
+		e := *cerr
+		e.Out += pr.base
+
+		return &e
+	}
+
+	return err
+}
+
+// sequential switches to decoding the job by a Reader,
+// it's either the rest of the stream or a segment.
+func (pr *ParallelReader) sequential(j *decodeJob) error {
+	r, err := NewReaderOptions(j.rest, pr.opts.Options)
+	if err != nil {
+		return err
+	}
+
+	if j.rest == nil {
+		r.ResetBytes(j.in)
+	}
+
+	r.boff = j.boff // magic is only required at the stream beginning
+	r.out = pr.out
+
+	pr.seq, pr.seqJob = r, j
+
+	return nil
+}
+
+// readSequential reads from the sequential decoder until p is full or the decoder is done.
+func (pr *ParallelReader) readSequential(p []byte) (n int, err error) {
+	for n < len(p) {
+		var m int
+
+		m, err = pr.seq.Read(p[n:])
+		n += m
+		pr.out += int64(m)
+
+		if errors.Is(err, ErrBreak) {
+			continue
+		}
+
+		if errors.Is(err, io.EOF) {
+			return n, pr.endSequential(nil)
+		}
+
+		if err != nil {
+			return n, pr.endSequential(err)
+		}
+	}
+
+	return n, nil
+}
+
+func (pr *ParallelReader) endSequential(err error) error {
+	j := pr.seqJob
+
+	// read error truncates the segment, it's the cause of unexpected EOF
+	if j.err != nil && (err == nil || errors.Is(err, io.ErrUnexpectedEOF)) {
+		err = j.err
+	}
+
+	pr.seq.Release()
+	pr.seq, pr.seqJob = nil, nil
+
+	if j.rest == nil {
+		pr.recycle(j)
+	}
+
+	return err
+}
+
+// Close stops decoding goroutines.
+func (pr *ParallelReader) Close() error {
+	pr.once.Do(func() { close(pr.stop) })
+
+	if pr.err == nil {
+		pr.err = ErrClosed
+	}
+
+	if pr.seq != nil {
+		pr.seq.Release()
+		pr.seq = nil
+	}
+
+	return nil
+}
+
+// split reads the stream and cuts it into segments at stream headers.
+// If a segment reaches pr.max without a header to cut at,
+// the rest of the stream is left for sequential decoding.
+func (pr *ParallelReader) split(r io.Reader, jobs chan *decodeJob) {
+	defer close(pr.queue)
+	defer close(jobs)
+
+	var d Decoder
+	var boff int64
+	var err error
+
+	j := pr.job()
+
+	i := 0
+	hdr := -1 // the first header meta in a row
+
+	for err == nil {
+		var cut, next int
+
+		cut, next, err = scanElement(d, j.in, i, &hdr)
+
+		if errors.Is(err, ErrShortBuffer) && len(j.in) >= pr.max {
+			j.rest = io.MultiReader(bytes.NewReader(j.in), r)
+			j.boff = boff
+
+			pr.sendSequential(j)
+
+			return
+		}
+
+		if errors.Is(err, ErrShortBuffer) {
+			err = pr.read(r, j)
+			continue
+		}
+
+		if err != nil {
+			// decoder will stop at the same place and report the error with the context
+			err = nil
+			break
+		}
+
+		i = next
+
+		if cut <= 0 || cut < pr.opts.SegmentSize {
+			continue
+		}
+
+		nj := pr.job()
+		nj.in = append(nj.in[:0], j.in[cut:]...)
+		nj.boff = boff + int64(cut)
+
+		j.in = j.in[:cut]
+
+		if !pr.send(j, jobs) {
+			return
+		}
+
+		j = nj
+		boff = nj.boff
+		i -= cut
+		if hdr >= 0 {
+			hdr -= cut
+		}
+	}
+
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	j.err = err
+
+	if len(j.in) != 0 || err != nil {
+		pr.send(j, jobs)
+	}
+}
+
+// splitIndexed reads the file by index segments.
+// Consecutive short segments are joined up to SegmentSize,
+// and the rest of the file starting with a segment longer than pr.max
+// is left for sequential decoding.
+func (pr *ParallelReader) splitIndexed(f *IndexedFile, jobs chan *decodeJob) {
+	defer close(pr.queue)
+	defer close(jobs)
+
+	x := f.Index
+
+	cuts := make([]int64, 0, len(x.Entries)+2)
+	cuts = append(cuts, 0)
+
+	for _, e := range x.Entries {
+		if e.In > cuts[len(cuts)-1] {
+			cuts = append(cuts, e.In)
+		}
+	}
+
+	if x.Size > cuts[len(cuts)-1] {
+		cuts = append(cuts, x.Size)
+	}
+
+	for k := 0; k+1 < len(cuts); {
+		st := cuts[k]
+
+		if cuts[k+1]-st > int64(pr.max) {
+			j := pr.job()
+			j.rest = io.NewSectionReader(f.f, st, x.Size-st)
+			j.boff = st
+
+			pr.sendSequential(j)
+
+			return
+		}
+
+		k++
+
+		for k+1 < len(cuts) && cuts[k]-st < int64(pr.opts.SegmentSize) && cuts[k+1]-st <= int64(pr.max) {
+			k++
+		}
+
+		j := pr.job()
+		j.boff = st
+
+		size := int(cuts[k] - st)
+		if cap(j.in) < size {
+			j.in = make([]byte, size)
+		}
+
+		j.in = j.in[:size]
+
+		_, err := f.f.ReadAt(j.in, st)
+		if err != nil {
+			j.in = j.in[:0]
+			j.err = err
+		}
+
+		if !pr.send(j, jobs) || err != nil {
+			return
+		}
+	}
+}
+
+// scanElement parses one element without decoding it.
+// cut is the stream start position if the element is MetaReset, -1 otherwise.
+// hdr tracks the first of consecutive Magic and Ver metas which belong to the next stream header.
+func scanElement(d Decoder, b []byte, st int, hdr *int) (cut, i int, err error) {
+	cut = -1
+
+	e, i, err := parseElement(d, b, st)
+	if err != nil {
+		return cut, st, err
+	}
+
+	if e.tag == 'l' {
+		if i+e.l > len(b) {
+			return cut, st, ErrShortBuffer
+		}
+
+		i += e.l
+	}
+
+	if e.tag == 'm' && (e.off == MetaMagic || e.off == MetaVer) {
+		if *hdr < 0 {
+			*hdr = e.st
+		}
+
+		return cut, i, nil
+	}
+
+	if e.tag == 'm' && e.off == MetaReset {
+		cut = e.st
+
+		if *hdr >= 0 {
+			cut = *hdr
+		}
+	}
+
+	*hdr = -1
+
+	return cut, i, nil
+}
+
+func (pr *ParallelReader) read(r io.Reader, j *decodeJob) error {
+	if len(j.in) == cap(j.in) {
+		size := 2 * cap(j.in)
+		if size < pr.opts.BufferSize {
+			size = pr.opts.BufferSize
+		}
+
+		if size > pr.max {
+			size = pr.max
+		}
+
+		in := make([]byte, len(j.in), size)
+		copy(in, j.in)
+		j.in = in
+	}
+
+	n, err := r.Read(j.in[len(j.in):cap(j.in)])
+	j.in = j.in[:len(j.in)+n]
+
+	if n != 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	return err
+}
+
+func (pr *ParallelReader) send(j *decodeJob, jobs chan *decodeJob) bool {
+	select {
+	case pr.queue <- j:
+	case <-pr.stop:
+		return false
+	}
+
+	select {
+	case jobs <- j:
+	case <-pr.stop:
+		return false
+	}
+
+	return true
+}
+
+// sendSequential queues the job with the rest of the stream, it's not decoded by workers.
+func (pr *ParallelReader) sendSequential(j *decodeJob) {
+	j.done <- struct{}{}
+
+	select {
+	case pr.queue <- j:
+	case <-pr.stop:
+	}
+}
+
+func (pr *ParallelReader) decodeSegments(jobs chan *decodeJob) {
+	r, _ := NewReaderOptions(nil, pr.opts.Options) // options are checked by newParallelReader
+
+	// output over maxOut is not an error, the segment is decoded sequentially then
+	overflow := pr.opts.OutputLimit == 0 || pr.opts.OutputLimit > int64(pr.maxOut)
+
+	r.OutputLimit = pr.opts.OutputLimit
+	if overflow {
+		r.OutputLimit = int64(pr.maxOut)
+	}
+
+	for j := range jobs {
+		var err error
+
+		r.ResetBytes(j.in)
+		r.boff = j.boff // magic is only required at the stream beginning
+
+		// segment output is counted from zero,
+		// so the limits are only exceeded here if they are exceeded for the whole output
+		j.out, err = decodeAll(r, j.out[:0])
+
+		if overflow && errors.Is(err, ErrOutputLimit) {
+			j.out = j.out[:0]
+			j.overflow = true
+			err = nil
+		}
+
+		// read error truncates the segment, it's the cause of unexpected EOF
+		if err != nil && (j.err == nil || !errors.Is(err, io.ErrUnexpectedEOF)) {
+			j.err = err
+		}
+
+		j.done <- struct{}{}
+	}
+}
+
+// decodeAll decodes the whole stream skipping Break markers.
+func decodeAll(r *Reader, out []byte) ([]byte, error) {
+	for {
+		if len(out) == cap(out) {
+			out = append(out, 0)[:len(out)]
+		}
+
+		n, err := r.Read(out[len(out):cap(out)])
+		out = out[:len(out)+n]
+
+		switch {
+		case errors.Is(err, io.EOF):
+			return out, nil
+		case errors.Is(err, ErrBreak):
+		case err != nil:
+			return out, err
+		}
+	}
+}
+
+func (pr *ParallelReader) job() *decodeJob {
+	select {
+	case j := <-pr.free:
+		return j
+	default:
+	}
+
+	return &decodeJob{
+		done: make(chan struct{}, 1),
+	}
+}
+
+func (pr *ParallelReader) recycle(j *decodeJob) {
+	j.in = j.in[:0]
+	j.out = j.out[:0]
+	j.boff = 0
+	j.err = nil
+	j.rest = nil
+	j.overflow = false
+
+	select {
+	case pr.free <- j:
+	default:
+	}
+}
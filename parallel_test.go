@@ -0,0 +1,373 @@
+package eazy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressParallel(t *testing.T) {
+	data := testLogData(1 << 20)
+
+	var b Buf
+
+	opts := ParallelOptions{
+		Options:     DefaultOptions(),
+		SegmentSize: 64 * KiB,
+		Workers:     4,
+		Align:       4 * KiB,
+	}
+
+	opts.BlockSize = 16 * KiB
+
+	n, err := CompressParallel(&b, bytes.NewReader(data), opts)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(b)), n)
+	assert.Less(t, len(b), len(data)/2)
+
+	segments := 0
+
+	for i := 0; i < len(b); {
+		j := bytes.Index(b[i:], []byte(Magic))
+		if j < 0 {
+			break
+		}
+
+		assert.Zero(t, (i+j)%opts.Align, "segment offset %x", i+j)
+
+		segments++
+		i += j + 1
+	}
+
+	assert.Equal(t, (len(data)+opts.SegmentSize-1)/opts.SegmentSize, segments)
+
+	dec, err := io.ReadAll(NewReader(&BufReader{Buf: b}))
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data, dec))
+}
+
+func TestCompressParallelErrors(t *testing.T) {
+	var b Buf
+
+	_, err := CompressParallel(&b, bytes.NewReader(nil), ParallelOptions{Workers: -1})
+	assert.ErrorIs(t, err, ErrBadOption)
+
+	_, err = CompressParallel(&failWriter{w: &b, fail: []int{0}}, bytes.NewReader(testLogData(1<<16)), ParallelOptions{SegmentSize: 1 << 10})
+	assert.ErrorIs(t, err, errFail)
+}
+
+// testLogData generates log-like text for tests which don't need a real log file.
+func testLogData(size int) []byte {
+	rnd := rand.New(rand.NewSource(0))
+
+	paths := []string{"/", "/api/v1/users", "/api/v1/orders", "/static/app.js", "/healthz"}
+	levels := []string{"info", "debug", "warn", "error"}
+
+	b := make([]byte, 0, size+200)
+
+	for i := 0; len(b) < size; i++ {
+		b = fmt.Appendf(b, "2024-01-02T15:04:%02d.%06dZ  %-5s  request  path=%s  status=%d  dur=%dus  req_id=%08x\n",
+			i/1000%60, i%1000*997, levels[rnd.Intn(len(levels))], paths[rnd.Intn(len(paths))],
+			200+100*rnd.Intn(4), rnd.Intn(100000), rnd.Uint32())
+	}
+
+	return b[:size]
+}
+
+func TestParallelReader(t *testing.T) {
+	data := testLogData(1 << 20)
+
+	var b Buf
+
+	copts := ParallelOptions{
+		Options:     DefaultOptions(),
+		SegmentSize: 32 * KiB,
+		Align:       1 * KiB,
+	}
+
+	copts.BlockSize = 16 * KiB
+
+	_, err := CompressParallel(&b, bytes.NewReader(data), copts)
+	require.NoError(t, err)
+
+	for _, seg := range []int{1, 100 * KiB, 10 * MiB} {
+		t.Run(fmt.Sprintf("seg_%x", seg), func(t *testing.T) {
+			r, err := NewParallelReader(&BufReader{Buf: b}, ParallelOptions{
+				Options:     Options{RequireMagic: true, BufferSize: 4 * KiB},
+				SegmentSize: seg,
+				Workers:     4,
+			})
+			require.NoError(t, err)
+
+			defer r.Close()
+
+			dec, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(data, dec))
+		})
+	}
+
+	t.Run("Truncated", func(t *testing.T) {
+		r, err := NewParallelReader(&BufReader{Buf: b[:len(b)-3]}, ParallelOptions{SegmentSize: 1})
+		require.NoError(t, err)
+
+		dec, err := io.ReadAll(r)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, data[:len(dec)], dec)
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		// find an element in a middle segment
+		var d Decoder
+		i := 0
+
+		for i < len(b)*2/3 {
+			e, next, err := parseElement(d, b, i)
+			require.NoError(t, err)
+
+			i = next
+			if e.tag == 'l' {
+				i += e.l
+			}
+		}
+
+		var e Encoder
+
+		c := append(Buf{}, b[:i]...)
+		c = e.Tag(c, Copy, 4)
+		c = e.Offset(c, 1<<20, 4) // overflow
+		c = append(c, b[i:]...)
+
+		exp, experr := Decode(nil, c)
+		require.Error(t, experr)
+
+		r, err := NewParallelReader(&BufReader{Buf: c}, ParallelOptions{SegmentSize: 1, Workers: 4})
+		require.NoError(t, err)
+
+		defer r.Close()
+
+		dec, err := io.ReadAll(r)
+		assert.Equal(t, experr, err)
+		assert.True(t, bytes.Equal(exp, dec))
+	})
+
+	t.Run("ReadError", func(t *testing.T) {
+		r, err := NewParallelReader(io.MultiReader(bytes.NewReader(b[:len(b)/2]), iotest.ErrReader(errFail)), ParallelOptions{SegmentSize: 1})
+		require.NoError(t, err)
+
+		dec, err := io.ReadAll(r)
+		assert.ErrorIs(t, err, errFail)
+		assert.Equal(t, data[:len(dec)], dec)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		r, err := NewParallelReader(&BufReader{Buf: b}, ParallelOptions{SegmentSize: 1, Workers: 2})
+		require.NoError(t, err)
+
+		p := make([]byte, 100)
+
+		_, err = r.Read(p)
+		assert.NoError(t, err)
+
+		err = r.Close()
+		assert.NoError(t, err)
+
+		_, err = r.Read(p)
+		assert.ErrorIs(t, err, ErrClosed)
+	})
+}
+
+func TestParallelReaderLimits(t *testing.T) {
+	data := testLogData(256 * KiB)
+
+	var b Buf
+
+	_, err := CompressParallel(&b, bytes.NewReader(data), ParallelOptions{
+		Options:     Options{BlockSize: 4 * KiB},
+		SegmentSize: 16 * KiB,
+	})
+	require.NoError(t, err)
+
+	// highly compressible stream
+	w := NewWriter(&b, 4*KiB, 256)
+
+	_, err = w.Write(make([]byte, 8*MiB))
+	require.NoError(t, err)
+
+	data = append(data, make([]byte, 8*MiB)...)
+
+	for _, seg := range []int{1, 10 * MiB} {
+		r, err := NewParallelReader(&BufReader{Buf: b}, ParallelOptions{
+			Options:     Options{OutputLimit: 1000},
+			SegmentSize: seg,
+			Workers:     2,
+		})
+		require.NoError(t, err)
+
+		dec, err := io.ReadAll(r)
+		assert.ErrorIs(t, err, ErrOutputLimit, "segment size %x", seg)
+		assert.Equal(t, data[:1000], dec, "segment size %x", seg)
+
+		_ = r.Close()
+	}
+
+	r, err := NewParallelReader(&BufReader{Buf: b}, ParallelOptions{
+		Options: Options{RatioLimit: 20},
+		Workers: 2,
+	})
+	require.NoError(t, err)
+
+	dec, err := io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrRatioLimit)
+	assert.Equal(t, data[:len(dec)], dec)
+	assert.Less(t, len(dec), len(data))
+
+	_ = r.Close()
+
+	// segment expanding over the max output size is decoded sequentially
+	r, err = NewParallelReader(&BufReader{Buf: b}, ParallelOptions{
+		Options: Options{BufferSizeLimit: 16 * KiB},
+		Workers: 2,
+	})
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	assert.Equal(t, 16*KiB, r.max)
+
+	dec = dec[:0]
+	p := make([]byte, 1*KiB)
+	seq := false
+
+	for err == nil {
+		var n int
+
+		n, err = r.Read(p)
+		dec = append(dec, p[:n]...)
+		seq = seq || r.seq != nil
+	}
+
+	assert.ErrorIs(t, err, io.EOF)
+	assert.True(t, bytes.Equal(data, dec))
+	assert.True(t, seq)
+}
+
+// mixedStreams returns parallel compressed segments followed by a long stream without resets.
+func mixedStreams(t *testing.T) (data, b []byte) {
+	t.Helper()
+
+	data = testLogData(1 << 20)
+
+	var enc Buf
+
+	_, err := CompressParallel(&enc, bytes.NewReader(data[:len(data)/2]), ParallelOptions{
+		Options:     Options{BlockSize: 1 * KiB},
+		SegmentSize: 4 * KiB,
+	})
+	require.NoError(t, err)
+
+	w := NewWriter(&enc, 1*KiB, 256)
+
+	_, err = w.Write(data[len(data)/2:])
+	require.NoError(t, err)
+
+	return data, enc
+}
+
+func TestParallelReaderSequential(t *testing.T) {
+	data, b := mixedStreams(t)
+
+	cr := &countingReader{r: &BufReader{Buf: b}}
+
+	r, err := NewParallelReader(cr, ParallelOptions{
+		Options:     Options{BlockSize: 1 * KiB, RequireMagic: true},
+		SegmentSize: 1 * KiB,
+		Workers:     2,
+	})
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	p := make([]byte, len(data)/2+100)
+
+	_, err = io.ReadFull(r, p)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data[:len(p)], p))
+	assert.NotNil(t, r.seq)
+	assert.Less(t, cr.n, int64(len(b)), "the whole stream is read before decoding")
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(data[len(p):], rest))
+}
+
+func TestParallelReaderIndexed(t *testing.T) {
+	data, b := mixedStreams(t)
+
+	x, err := BuildIndex(bytes.NewReader(b))
+	require.NoError(t, err)
+
+	name := filepath.Join(t.TempDir(), "data.ez")
+
+	err = os.WriteFile(name, b, 0o644)
+	require.NoError(t, err)
+
+	err = WriteIndexFile(name+IndexExt, x)
+	require.NoError(t, err)
+
+	f, err := OpenIndexed(name)
+	require.NoError(t, err)
+
+	defer func() {
+		err := f.Close()
+		assert.NoError(t, err)
+	}()
+
+	for _, seg := range []int{1, 16 * KiB, 10 * MiB} {
+		r, err := NewParallelReaderIndexed(f, ParallelOptions{
+			Options:     Options{BlockSize: 1 * KiB, RequireMagic: true},
+			SegmentSize: seg,
+			Workers:     4,
+		})
+		require.NoError(t, err)
+
+		var dec []byte
+		var seq bool
+
+		p := make([]byte, 1*KiB)
+
+		for err == nil {
+			var n int
+
+			n, err = r.Read(p)
+			dec = append(dec, p[:n]...)
+			seq = seq || r.seq != nil
+		}
+
+		assert.ErrorIs(t, err, io.EOF)
+		assert.True(t, bytes.Equal(data, dec), "segment size %x", seg)
+		assert.Equal(t, seg != 10*MiB, seq, "segment size %x", seg)
+
+		_ = r.Close()
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.n += int64(n)
+
+	return n, err
+}
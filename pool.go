@@ -0,0 +1,47 @@
+package eazy
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// pools are sync.Pools of byte slices by log2 size.
+// Each slice has extra bytes of capacity after the power of two size.
+type pools struct {
+	p     [33]sync.Pool
+	extra int
+}
+
+var (
+	bufPool   = pools{}
+	blockPool = pools{extra: wildSlack}
+)
+
+// get returns a slice of size rounded up to a power of two plus extra bytes.
+func (ps *pools) get(size int) *[]byte {
+	c := bits.Len(uint(size - 1))
+
+	if p, ok := ps.p[c].Get().(*[]byte); ok {
+		return p
+	}
+
+	b := make([]byte, 1<<c+ps.extra)
+
+	return &b
+}
+
+// put returns p to the pool. Slices not taken from get are ignored.
+func (ps *pools) put(p *[]byte) {
+	if p == nil {
+		return
+	}
+
+	size := len(*p) - ps.extra
+	c := bits.Len(uint(size - 1))
+
+	if size <= 0 || 1<<c != size {
+		return
+	}
+
+	ps.p[c].Put(p)
+}
@@ -0,0 +1,105 @@
+package eazy
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderBufferReuse(t *testing.T) {
+	data := testLogData(256 * KiB)
+
+	var enc Buf
+
+	w := NewWriter(&enc, 64*KiB, 1024)
+
+	_, err := w.Write(data)
+	require.NoError(t, err)
+
+	br := bytes.NewReader(enc)
+	r := NewReader(br)
+	r.BufferSize = 4 * KiB
+
+	p := make([]byte, 1000)
+
+	var n int
+
+	read := func() {
+		br.Reset(enc)
+		r.Reset(br)
+
+		n = 0
+
+		for {
+			m, err := r.Read(p)
+			n += m
+
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	read()
+
+	assert.Equal(t, len(data), n)
+
+	assert.Equal(t, 4*KiB+64*KiB+wildSlack, r.MemoryUsage())
+
+	allocs := testing.AllocsPerRun(100, read)
+	assert.Zero(t, allocs)
+	assert.Equal(t, len(data), n)
+
+	allocs = testing.AllocsPerRun(100, func() {
+		br.Reset(enc)
+		r := NewReader(br)
+
+		_, err = r.WriteTo(io.Discard)
+
+		r.Release()
+	})
+
+	assert.NoError(t, err)
+
+	if !raceEnabled {
+		assert.LessOrEqual(t, allocs, 1.0) // Reader itself
+	}
+
+	assert.Equal(t, cap(w.b)+64*KiB+1024*4, w.MemoryUsage())
+}
+
+func TestReaderBufferSizeLimit(t *testing.T) {
+	const someMeta = MetaTagMask
+
+	var b Buf
+
+	w := NewWriter(&b, 1024, 32)
+
+	err := w.WriteHeader()
+	require.NoError(t, err)
+
+	b = w.e.Meta(b, someMeta, 100*KiB)
+	b = append(b, make([]byte, 100*KiB)...)
+
+	_, err = w.Write([]byte("data"))
+	require.NoError(t, err)
+
+	r := NewReader(bytes.NewReader(b))
+	r.BufferSize = 4 * KiB
+	r.BufferSizeLimit = 64 * KiB
+	r.SkipUnsupportedMeta = true
+
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrBufferOverLimit)
+
+	r.Reset(bytes.NewReader(b))
+	r.BufferSizeLimit = 0
+
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.Equal(t, 128*KiB+1024+wildSlack, r.MemoryUsage())
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package eazy
+
+// sync.Pool randomly drops items under race detector,
+// so allocation counts are not stable.
+const raceEnabled = true
@@ -2,9 +2,13 @@ package eazy
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/bits"
+	"unsafe"
 )
 
 type (
@@ -29,9 +33,41 @@ type (
 		RequireMagic        bool
 		SkipUnsupportedMeta bool
 
+		// BufferSizeLimit is the max input buffer size.
+		// Buffer only grows if an element header or meta data doesn't fit into it.
+		// 0 means no limit.
+		BufferSizeLimit int
+
+		// OutputLimit is the max total decompressed size.
+		// 0 means no limit.
+		OutputLimit int64
+
+		// RatioLimit is the max decompressed to compressed size ratio.
+		// It's checked after the first ratioMinOut (1 MiB) of output.
+		// 0 means no limit.
+		RatioLimit float64
+
+		// Follow makes Reader treat io.EOF in the middle of an element
+		// as no more data yet instead of io.ErrUnexpectedEOF.
+		// Read returns io.EOF with the partial element kept
+		// and can be called again once more data is appended to the input.
+		// That is for reading files which are still being written.
+		Follow bool
+
+		out int64 // total output
+
+		// current stream header compressed offset and output before it
+		stream, streamOut int64
+		hdr, hdrEnd       int64 // the last header metas sequence
+
+		// pooled buffers
+		buf *[]byte // input
+		win *[]byte // block
+
 		// current tag
-		state    byte
-		off, len int // off is absolute value
+		state     byte
+		off, len  int   // off is absolute value
+		ein, eout int64 // element start offsets for errors
 
 		// input
 		b    []byte
@@ -39,6 +75,36 @@ type (
 		boff int64 // buffer b offset in the input stream
 	}
 
+	// LimitError is returned when Reader OutputLimit or RatioLimit is exceeded,
+	// or when a block size or an element is bigger than BlockSizeLimit.
+	// Err is ErrOutputLimit, ErrRatioLimit or ErrBlockSizeOverLimit.
+	LimitError struct {
+		Err error
+
+		In  int64 // compressed bytes read
+		Out int64 // decompressed bytes returned
+	}
+
+	// CorruptError is returned when the compressed stream is malformed or truncated.
+	// Err is the reason, one of the sentinel errors such as ErrOverflow,
+	// ErrBadMagic, ErrMissedMeta or io.ErrUnexpectedEOF,
+	// so errors.Is works as with bare errors.
+	CorruptError struct {
+		Err error
+
+		In   int64  // compressed stream offset of the element
+		Out  int64  // decompressed bytes returned before the element
+		Kind string // element kind: literal, copy or meta, empty if unknown
+	}
+
+	// element is a stream element parsed by parseElement.
+	element struct {
+		st  int  // element start after padding
+		tag byte // 'l' literal, 'c' copy, 'm' meta
+		l   int  // literal or copy length, meta data length
+		off int  // copy offset, meta tag
+	}
+
 	// Dumper is a debug printer for compressed data.
 	Dumper struct {
 		io.Writer
@@ -49,14 +115,34 @@ type (
 
 		GlobalOffset int64
 
+		// KeepWindow makes Dumper decode the stream alongside,
+		// so that Copy lines include the output offset the copy is from
+		// and the quoted text it expands to.
+		// See Copied for using it from Debug.
+		KeepWindow bool
+
+		// BlockSizeLimit is the same as Reader.BlockSizeLimit.
+		// It limits the window and copy sizes for KeepWindow.
+		// NewDumper sets it to DefaultBlockSizeLimit, zero means no limit.
+		BlockSizeLimit int
+
 		b []byte
 		p []byte // for ReadFrom
+
+		// decode window for KeepWindow
+		win    []byte
+		wpos   int64 // stream output position
+		copied []byte
+		src    int64
 	}
 )
 
 var (
 	ErrBadMagic           = errors.New("bad magic")
 	ErrBlockSizeOverLimit = errors.New("block size is more than the limit")
+	ErrBufferOverLimit    = errors.New("buffer size is more than the limit")
+	ErrOutputLimit        = errors.New("output size limit exceeded")
+	ErrRatioLimit         = errors.New("compression ratio limit exceeded")
 	ErrNoMagic            = errors.New("no magic")
 	ErrOverflow           = errors.New("length/offset overflow")
 	ErrShortBuffer        = io.ErrShortBuffer
@@ -73,14 +159,25 @@ var (
 	// It's written with Writer.WriteBreak method.
 	// Reader stays valid after returning this error.
 	ErrBreak = errors.New("break point")
+
+	// ErrMissedMeta is returned when data elements go before the stream header.
+	ErrMissedMeta = errors.New("missed meta")
+)
+
+const (
+	wildSlack = 16 // block capacity after the end for wildCopy
+	wildMax   = 64 // longer elements are copied by copy
+
+	ratioMinOut = 1 * MiB
 )
 
 // NewReader creates new decompressor reading from r.
 func NewReader(r io.Reader) *Reader {
 	return &Reader{
-		Reader:         r,
-		BlockSizeLimit: 16 * MiB,
-		BufferSize:     64 * 1024,
+		Reader:          r,
+		BlockSizeLimit:  DefaultBlockSizeLimit,
+		BufferSize:      DefaultBufferSize,
+		BufferSizeLimit: DefaultBufferSizeLimit,
 	}
 }
 
@@ -93,8 +190,9 @@ func NewReaderBytes(b []byte) *Reader {
 }
 
 // Reset resets the stream.
+// Buffers are reused.
 func (r *Reader) Reset(rd io.Reader) {
-	r.ResetBytes(r.b[:0])
+	r.ResetBytes(nil)
 	r.Reader = rd
 }
 
@@ -105,116 +203,429 @@ func (r *Reader) ResetBytes(b []byte) {
 
 	r.block = r.block[:0]
 	r.pos = 0
+	r.out = 0
 
 	r.i = 0
 	r.boff = 0
 
+	r.stream, r.streamOut = 0, 0
+	r.hdr, r.hdrEnd = 0, 0
+
 	r.state = 0
 }
 
 // Read reads data from underlaying reader and decompresses it into p.
 func (r *Reader) Read(p []byte) (n int, err error) {
-	var m, i int
-
-	for n < len(p) && err == nil {
-		m, i, err = r.read(p[n:], r.i)
+	return r.ReadContext(context.Background(), p)
+}
 
-		n += m
-		r.i = i
+// ReadContext is Read which checks ctx between decoded batches.
+// Each batch is at most the block size, so decoding stops promptly
+// once ctx is canceled, but a blocked underlaying Read is not interrupted.
+// Data decoded before cancellation is returned along with ctx.Err().
+func (r *Reader) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	var q []byte
 
-		if n == len(p) {
-			//	err = nil
+	for n < len(p) {
+		if err = ctx.Err(); err != nil {
 			break
 		}
 
-		if err != ErrShortBuffer { //nolint:errorlint
-			continue
-		}
+		q, err = r.decode(len(p) - n)
+		n += copy(p[n:], q)
 
-		err = r.more()
-		if errors.Is(err, io.EOF) && (r.state != 0 || r.i < len(r.b)) {
-			err = io.ErrUnexpectedEOF
+		if err != nil {
+			break
 		}
 	}
 
 	return n, err
 }
 
-func (r *Reader) read(p []byte, st int) (n, i int, err error) {
-	//	defer func() { println("eazy.Decoder.read", st, i, n, err, r.state, r.len, len(r.b)) }()
-	i = st
+// Next decodes the next chunk of data and returns it without copying.
+// The result points to the Reader window and is valid until the next call
+// to any Reader method.
+// Chunks are not aligned to Writes,
+// a chunk may include multiple records or a part of one.
+//
+// Empty result is never returned with nil error.
+// Errors are the same as of Read, including ErrBreak.
+func (r *Reader) Next() ([]byte, error) {
+	return r.decode(math.MaxInt)
+}
+
+// WriteTo implements io.WriterTo.
+// Data is decoded into the window and written from there,
+// so no intermediate buffer is used and many elements are written at once.
+//
+// As Read it returns ErrBreak when Break marker is reached,
+// and the next call continues after it.
+func (r *Reader) WriteTo(w io.Writer) (n int64, err error) {
+	return r.WriteToContext(context.Background(), w)
+}
+
+// WriteToContext is WriteTo which checks ctx before each decoded batch.
+// See ReadContext.
+func (r *Reader) WriteToContext(ctx context.Context, w io.Writer) (n int64, err error) {
+	for {
+		if err = ctx.Err(); err != nil {
+			return n, err
+		}
 
-	for r.state == 0 {
-		i, err = r.readTag(i)
+		p, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
 		if err != nil {
-			return
+			return n, err
 		}
-	}
 
-	if len(r.block) == 0 {
-		return 0, st, errors.New("missed meta")
-	}
+		m, err := w.Write(p)
+		n += int64(m)
 
-	if r.state == 'l' && i == len(r.b) {
-		return 0, i, ErrShortBuffer
+		if err == nil && m != len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return n, err
+		}
 	}
+}
 
-	end := r.len
-	if end > len(p) {
-		end = len(p)
-	}
+// decode decodes up to max bytes into the window and returns them.
+// It decodes as many elements as possible,
+// but the result is never wrapped around the window end.
+// The result is valid until the next call.
+//
+// Data and error are never returned together,
+// if decoding stops with an error after some data is decoded,
+// the data is returned and the error is returned by the next call.
+//
+// Short elements are copied by 8 bytes words which may write past the element end.
+// Overwritten bytes are saved and restored, and the block has wildSlack bytes
+// capacity after its end for that.
+func (r *Reader) decode(max int) (p []byte, err error) {
+	var st, n int
+
+	wild := cap(r.block)-len(r.block) >= wildSlack
+	lim := max
+	est := r.i // element start
+
+	for n < max {
+		if r.state == 0 {
+			// meta may reset the window, return the data first
+			if n != 0 && r.nextMeta() {
+				break
+			}
 
-	//	dpr("read %q  %x %x %x   %x %x   %x %x", r.state, r.pos, r.off, r.len, st, i, end, len(p))
+			est = r.i
 
-	switch {
-	case r.state == 'l':
-		end = copy(p[:end], r.b[i:])
-		i += end
-	case int64(r.off+r.len) <= r.pos:
-		end = copy(p[:end], r.block[r.off&r.mask:])
-		r.off += end
-	case int64(r.off) == r.pos: // zero region
-		for j := 0; j < end; {
-			j += copy(p[j:end], zeros)
+			if r.shortTag() {
+				continue
+			}
+
+			i, err := r.readTag(r.i)
+			if n != 0 && err != nil {
+				break
+			}
+
+			r.i = i
+
+			if errors.Is(err, ErrShortBuffer) {
+				err = r.fill()
+			} else if err != nil && !errors.Is(err, ErrBreak) {
+				err = r.corrupt(err, i)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			continue
 		}
-	default:
-		//           < previous reads, copy from r.block
-		//          >  runlen encoded, copy from p
-		// abcd______abcd______abcd______
-		// ^ r.off   ^ r.pos                // r.block pos
-		// ^ 0       ^ run          ^ end   // p pos
 
-		run := int(r.pos) - r.off
-		if run > len(p) {
-			run = len(p)
+		if len(r.block) == 0 {
+			return nil, r.corrupt(ErrMissedMeta, est)
+		}
+
+		dst := int(r.pos) & r.mask
+		if n == 0 {
+			st = dst
+
+			lim, err = r.limit(max)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		for j := 0; j < run; {
-			j += copy(p[j:run], r.block[(r.off+j)&r.mask:])
+		if n == lim {
+			break
 		}
 
-		for j := run; j < end; {
-			j += copy(p[j:end], p[:j])
+		m := len(r.block) - dst
+		if m > r.len {
+			m = r.len
+		}
+		if m > lim-n {
+			m = lim - n
 		}
 
-		r.off += end
-	}
+		switch dist := int(r.pos) - r.off; {
+		case r.state == 'l':
+			if r.i == len(r.b) {
+				if n != 0 {
+					return r.block[st : st+n], nil
+				}
 
-	r.len -= end
+				err = r.fill()
+				if err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			if m > len(r.b)-r.i {
+				m = len(r.b) - r.i
+			}
+
+			if wild && m <= wildMax && r.i+m+8 <= len(r.b) {
+				wildCopy(r.block, dst, r.b[r.i:], m)
+			} else {
+				copy(r.block[dst:dst+m], r.b[r.i:])
+			}
+
+			r.i += m
+		case dist == 0: // zero region
+			for j := dst; j < dst+m; {
+				j += copy(r.block[j:dst+m], zeros)
+			}
+
+			r.off += m
+		default:
+			src := r.off & r.mask
+
+			if m > len(r.block)-src {
+				m = len(r.block) - src
+			}
+
+			switch {
+			case dist >= 8 && wild && m <= wildMax:
+				// 8 bytes words never overlap, so runlen sequences are correct
+				wildCopy(r.block, dst, r.block[src:], m)
+			case dist >= m:
+				copy(r.block[dst:dst+m], r.block[src:src+m])
+			case src < dst:
+				// runlen: the first dist bytes repeat, copy them doubling the chunk
+				copy(r.block[dst:dst+dist], r.block[src:dst])
+
+				for j := dist; j < m; {
+					j += copy(r.block[dst+j:dst+m], r.block[dst:dst+j])
+				}
+			default:
+				// source is wrapped around the window end
+				m = dist
+				copy(r.block[dst:dst+m], r.block[src:src+m])
+			}
+
+			r.off += m
+		}
 
-	for n < end {
-		m := copy(r.block[int(r.pos)&r.mask:], p[n:end])
-		n += m
 		r.pos += int64(m)
+		r.out += int64(m)
+		r.len -= m
+		n += m
+
+		if r.len == 0 {
+			r.state = 0
+		}
+
+		if dst+m == len(r.block) {
+			break
+		}
 	}
 
-	if r.len == 0 {
-		r.state = 0
+	return r.block[st : st+n], nil
+}
+
+// limit checks OutputLimit and RatioLimit before decoding more data
+// and returns how much can be decoded.
+func (r *Reader) limit(max int) (int, error) {
+	if r.OutputLimit != 0 {
+		rem := r.OutputLimit - r.out
+		if rem <= 0 {
+			return 0, r.limitError(ErrOutputLimit)
+		}
+
+		if int64(max) > rem {
+			max = int(rem)
+		}
 	}
 
-	return
+	if r.RatioLimit != 0 && r.out >= ratioMinOut && float64(r.out) > r.RatioLimit*float64(r.boff+int64(r.i)) {
+		return 0, r.limitError(ErrRatioLimit)
+	}
+
+	return max, nil
+}
+
+func (r *Reader) limitError(err error) *LimitError {
+	return &LimitError{
+		Err: err,
+		In:  r.boff + int64(r.i),
+		Out: r.out,
+	}
 }
 
+// Error implements error.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v (in %d, out %d)", e.Err, e.In, e.Out)
+}
+
+// Unwrap returns the sentinel error.
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// shortTag parses the most common literal and copy tags
+// with embedded length and offset without function calls.
+// It returns false if the tag must be parsed by readTag.
+func (r *Reader) shortTag() bool {
+	if r.i+2 > len(r.b) || r.i == 0 && r.boff == 0 {
+		return false
+	}
+
+	t := int(r.b[r.i])
+	l := t & TagLenMask
+
+	if l == 0 || l >= Len1 || r.BlockSizeLimit != 0 && l > r.BlockSizeLimit {
+		return false
+	}
+
+	r.ein, r.eout = r.boff+int64(r.i), r.out
+
+	if t&TagMask == Literal {
+		r.state = 'l'
+		r.off = 0
+		r.len = l
+		r.i++
+
+		return true
+	}
+
+	off := int(r.b[r.i+1])
+	if off >= Off1 || off+l > len(r.block) {
+		return false
+	}
+
+	r.state = 'c'
+	r.off = int(r.pos) - off - l
+	r.len = l
+	r.i += 2
+
+	return true
+}
+
+// wildCopy copies m bytes from src to b[dst:] by 8 bytes words.
+// Up to 7 bytes after b[dst+m] are overwritten and then restored,
+// so b must have 8 bytes of capacity after dst+m,
+// and src must have the same after m.
+func wildCopy(b []byte, dst int, src []byte, m int) {
+	d := unsafe.Pointer(&b[dst])
+	s := unsafe.Pointer(&src[0])
+
+	end := (*uint64)(unsafe.Add(d, m))
+	save := *end
+
+	for i := 0; i < m; i += 8 {
+		*(*uint64)(unsafe.Add(d, i)) = *(*uint64)(unsafe.Add(s, i))
+	}
+
+	*end = save
+}
+
+// nextMeta reports if the next element in the buffer is meta.
+func (r *Reader) nextMeta() bool {
+	i := r.i
+
+	for i < len(r.b) && r.b[i] == Padding {
+		i++
+	}
+
+	return i < len(r.b) && r.b[i] == Meta
+}
+
+// fill reads more data into the buffer.
+func (r *Reader) fill() error {
+	err := r.more()
+	if errors.Is(err, io.EOF) && !r.Follow && (r.state != 0 || r.i < len(r.b)) {
+		err = r.corrupt(io.ErrUnexpectedEOF, r.i)
+	}
+
+	return err
+}
+
+// corrupt wraps err into CorruptError for the element at st
+// or for the current element if it's partially decoded.
+// Offsets are the element start as Decode reports them.
+// ErrBlockSizeOverLimit is not corruption, it's wrapped into LimitError.
+func (r *Reader) corrupt(err error, st int) error {
+	in, out := r.boff+int64(st), r.out
+	if r.state != 0 {
+		in, out = r.ein, r.eout
+	}
+
+	if errors.Is(err, ErrBlockSizeOverLimit) {
+		return &LimitError{Err: err, In: in, Out: out}
+	}
+
+	return &CorruptError{
+		Err:  err,
+		In:   in,
+		Out:  out,
+		Kind: r.elementKind(st),
+	}
+}
+
+// elementKind returns the kind of the current element
+// or the one starting at st.
+func (r *Reader) elementKind(st int) string {
+	switch r.state {
+	case 'l':
+		return "literal"
+	case 'c':
+		return "copy"
+	}
+
+	return tagKind(r.b, st)
+}
+
+func tagKind(b []byte, st int) string {
+	for st < len(b) && b[st] == Padding {
+		st++
+	}
+
+	switch {
+	case st == len(b):
+		return ""
+	case b[st] == Meta:
+		return "meta"
+	case b[st]&TagMask == Copy:
+		return "copy"
+	default:
+		return "literal"
+	}
+}
+
+// Error implements error.
+func (e *CorruptError) Error() string {
+	if e.Kind == "" {
+		return fmt.Sprintf("corrupted stream at offset 0x%x (out 0x%x): %v", e.In, e.Out, e.Err)
+	}
+
+	return fmt.Sprintf("corrupted %s at offset 0x%x (out 0x%x): %v", e.Kind, e.In, e.Out, e.Err)
+}
+
+// Unwrap returns the reason.
+func (e *CorruptError) Unwrap() error { return e.Err }
+
 func (r *Reader) readTag(st int) (i int, err error) {
 	i = st
 
@@ -264,6 +675,7 @@ func (r *Reader) readTag(st int) (i int, err error) {
 		panic("unreachable")
 	}
 
+	r.ein, r.eout = r.boff+int64(st), r.out
 	r.len = l
 
 	return i, nil
@@ -275,7 +687,7 @@ func (r *Reader) continueMetaTag(st int) (i int, err error) {
 
 	meta, l, i, err := r.d.Meta(r.b, i)
 	if err != nil {
-		return
+		return st, err
 	}
 
 	if r.boff == 0 && st == 0 && meta != MetaMagic && r.RequireMagic {
@@ -292,6 +704,8 @@ func (r *Reader) continueMetaTag(st int) (i int, err error) {
 		return st, ErrUnsupportedMeta
 	}
 
+	r.header(meta, st, i+l)
+
 	switch meta {
 	case MetaMagic:
 		if !bytes.Equal(r.b[i:i+l], []byte("eazy")) {
@@ -304,9 +718,12 @@ func (r *Reader) continueMetaTag(st int) (i int, err error) {
 		}
 	case MetaReset:
 		bs := int(r.b[i])
-		if bs > 32 || l != 1 || r.BlockSizeLimit != 0 && 1<<bs > r.BlockSizeLimit {
+		if bs > 32 || l != 1 {
 			return st, ErrOverflow
 		}
+		if r.BlockSizeLimit != 0 && 1<<bs > r.BlockSizeLimit {
+			return st, ErrBlockSizeOverLimit
+		}
 
 		r.reset(bs)
 	case MetaBreak:
@@ -324,21 +741,32 @@ func (r *Reader) continueMetaTag(st int) (i int, err error) {
 	return i, nil
 }
 
-func (r *Reader) reset(bs int) {
-	bs = 1 << bs
+// header tracks the current stream header position.
+// Magic and version metas directly preceding the reset are part of the header.
+func (r *Reader) header(meta, st, end int) {
+	if meta != MetaMagic && meta != MetaVer && meta != MetaReset {
+		return
+	}
 
-	if bs <= cap(r.block) {
-		r.block = r.block[:bs]
+	if abs := r.boff + int64(st); abs != r.hdrEnd {
+		r.hdr = abs
+	}
 
-		for i := 0; i < bs; {
-			i += copy(r.block[i:], zeros)
-		}
-	} else {
-		r.block = make([]byte, bs)
+	r.hdrEnd = r.boff + int64(end)
+
+	if meta == MetaReset {
+		r.stream, r.streamOut = r.hdr, r.out
+	}
+}
+
+func (r *Reader) reset(bs int) {
+	r.window(1 << bs)
+
+	for i := 0; i < len(r.block); {
+		i += copy(r.block[i:], zeros)
 	}
 
 	r.pos = 0
-	r.mask = bs - 1
 
 	r.state = 0
 }
@@ -513,27 +941,75 @@ func (d Decoder) Meta(b []byte, st int) (meta, l, i int, err error) {
 	return
 }
 
+// parseElement parses the element header at st skipping padding before it.
+// For metas i points after the meta data.
+// For literals i points after the header, literal data is left to the caller.
+func parseElement(d Decoder, b []byte, st int) (e element, i int, err error) {
+	i = st
+
+	for i < len(b) && b[i] == Padding {
+		i++
+	}
+
+	e.st = i
+
+	tag, l, i, err := d.Tag(b, e.st)
+	if err != nil {
+		return e, st, err
+	}
+
+	e.l = l
+
+	switch {
+	case tag == Meta && l == 0:
+		e.tag = 'm'
+
+		e.off, e.l, i, err = d.Meta(b, i)
+		if err != nil {
+			return e, st, err
+		}
+
+		if i+e.l > len(b) {
+			return e, st, ErrShortBuffer
+		}
+
+		i += e.l
+	case tag == Literal:
+		e.tag = 'l'
+	default:
+		e.tag = 'c'
+
+		e.off, i, err = d.Offset(b, i, l)
+		if err != nil {
+			return e, st, err
+		}
+	}
+
+	return e, i, nil
+}
+
 func (r *Reader) more() (err error) {
 	if r.Reader == nil {
 		return io.EOF
 	}
 
-	end := copy(r.b, r.b[r.i:])
-	r.b = r.b[:end]
-	r.boff += int64(r.i)
-	r.i = 0
+	rest := len(r.b) - r.i
 
-	if len(r.b) == 0 {
-		r.b = make([]byte, r.BufferSize)
-	} else {
-		r.b = append(r.b, make([]byte, 1024)...)
+	if r.buf == nil || rest >= len(*r.buf) {
+		err = r.growBuffer(rest)
+		if err != nil {
+			return err
+		}
 	}
 
-	r.b = r.b[:cap(r.b)]
+	buf := *r.buf
 
-	n, err := r.Reader.Read(r.b[end:])
-	//	println("more", r.i, end, end+n, n, len(r.b))
-	r.b = r.b[:end+n]
+	copy(buf, r.b[r.i:])
+	r.boff += int64(r.i)
+	r.i = 0
+
+	n, err := r.Reader.Read(buf[rest:])
+	r.b = buf[:rest+n]
 
 	if n != 0 && errors.Is(err, io.EOF) {
 		err = nil
@@ -542,6 +1018,79 @@ func (r *Reader) more() (err error) {
 	return err
 }
 
+// growBuffer replaces the input buffer with a pooled one
+// big enough to hold rest unread bytes and some more.
+func (r *Reader) growBuffer(rest int) error {
+	size := r.BufferSize
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+
+	for size <= rest {
+		size *= 2
+	}
+
+	if r.BufferSizeLimit != 0 && size > r.BufferSizeLimit {
+		if rest >= r.BufferSizeLimit {
+			return ErrBufferOverLimit
+		}
+
+		size = r.BufferSizeLimit
+	}
+
+	p := bufPool.get(size)
+
+	copy(*p, r.b[r.i:])
+	r.b = (*p)[:rest]
+	r.boff += int64(r.i)
+	r.i = 0
+
+	bufPool.put(r.buf)
+	r.buf = p
+
+	return nil
+}
+
+// window sets block to a pooled buffer of bs size.
+// The buffer is not cleared.
+func (r *Reader) window(bs int) {
+	if r.win == nil || len(*r.win) < bs+wildSlack {
+		blockPool.put(r.win)
+		r.win = blockPool.get(bs)
+	}
+
+	r.block = (*r.win)[:bs]
+	r.mask = bs - 1
+}
+
+// Release returns Reader buffers to the pool.
+// Reader can be reused after Reset or ResetBytes,
+// but it's intended to be called when Reader is not needed anymore,
+// so that the next one doesn't allocate.
+func (r *Reader) Release() {
+	r.ResetBytes(nil)
+
+	bufPool.put(r.buf)
+	blockPool.put(r.win)
+
+	r.buf = nil
+	r.win = nil
+	r.block = nil
+}
+
+// MemoryUsage returns the size of buffers owned by Reader.
+func (r *Reader) MemoryUsage() (n int) {
+	if r.buf != nil {
+		n += cap(*r.buf)
+	}
+
+	if r.win != nil {
+		n += cap(*r.win)
+	}
+
+	return n
+}
+
 // Dump returns debug printed compressed buffer p.
 func Dump(p []byte) string {
 	var d Dumper
@@ -559,7 +1108,8 @@ func Dump(p []byte) string {
 // NewDumper creates new debug compressed stream printer.
 func NewDumper(w io.Writer) *Dumper {
 	return &Dumper{
-		Writer: w,
+		Writer:         w,
+		BlockSizeLimit: DefaultBlockSizeLimit,
 	}
 }
 
@@ -603,7 +1153,13 @@ func (w *Dumper) ReadFrom(r io.Reader) (tot int64, err error) {
 func (w *Dumper) Write(p []byte) (i int, err error) { //nolint:gocognit
 	w.b = w.b[:0]
 
+	var line int // current line start in w.b
+
 	defer func() {
+		if errors.Is(err, ErrShortBuffer) {
+			w.b = w.b[:line] // the element is printed by the next Write
+		}
+
 		w.r.boff += int64(i)
 
 		if w.GlobalOffset >= 0 {
@@ -623,6 +1179,8 @@ func (w *Dumper) Write(p []byte) (i int, err error) { //nolint:gocognit
 	var tag, l, meta int
 
 	for i < len(p) {
+		line = len(w.b)
+
 		if w.GlobalOffset >= 0 {
 			w.b = fmt.Appendf(w.b, "%6x  ", w.GlobalOffset+int64(i))
 		}
@@ -656,17 +1214,28 @@ func (w *Dumper) Write(p []byte) (i int, err error) { //nolint:gocognit
 		case tag == Meta && l == 0:
 			meta, l, i, err = w.r.d.Meta(p, i)
 			if err != nil {
-				return
+				return st, err
 			}
 
 			if i+l > len(p) {
-				return i, ErrShortBuffer
+				return st, ErrShortBuffer
 			}
 
 			if meta == MetaVer && l == 1 {
 				w.r.d.Ver = int(p[i])
 			}
 
+			if meta == MetaReset && l == 1 && w.KeepWindow {
+				if p[i] > 32 {
+					return st, ErrOverflow
+				}
+				if w.BlockSizeLimit != 0 && 1<<p[i] > w.BlockSizeLimit {
+					return st, ErrBlockSizeOverLimit
+				}
+
+				w.resetWindow(int(p[i]))
+			}
+
 			w.b = fmt.Appendf(w.b, "meta %2x %x  %-8q  % [3]x\n", meta>>3, l, p[i:i+l])
 
 			if w.Debug != nil {
@@ -676,11 +1245,15 @@ func (w *Dumper) Write(p []byte) (i int, err error) { //nolint:gocognit
 			i += l
 		case tag == Literal:
 			if i+l > len(p) {
-				return i, ErrShortBuffer
+				return st, ErrShortBuffer
 			}
 
 			w.b = fmt.Appendf(w.b, "lit  %4x        %q\n", l, p[i:i+l])
 
+			if w.KeepWindow {
+				w.writeWindow(p[i : i+l])
+			}
+
 			if w.Debug != nil {
 				w.Debug(w.r.boff+int64(st), w.r.boff+int64(i), w.r.pos, 'l', l, 0)
 			}
@@ -700,7 +1273,26 @@ func (w *Dumper) Write(p []byte) (i int, err error) { //nolint:gocognit
 				return st, err
 			}
 
-			w.b = fmt.Appendf(w.b, "copy %4x  off %4x%s\n", l, off, long)
+			if w.KeepWindow {
+				err = w.checkCopy(l, off)
+				if err != nil {
+					return st, err
+				}
+			}
+
+			w.b = fmt.Appendf(w.b, "copy %4x  off %4x%s", l, off, long)
+
+			if w.KeepWindow {
+				w.copyWindow(l, off)
+
+				if off != 0 {
+					w.b = fmt.Appendf(w.b, "  from %x", w.src)
+				}
+
+				w.b = fmt.Appendf(w.b, "  %q", w.copied)
+			}
+
+			w.b = append(w.b, '\n')
 
 			if w.Debug != nil {
 				w.Debug(w.r.boff+int64(st), w.r.boff+int64(i), w.r.pos, 'c', l, off)
@@ -713,6 +1305,80 @@ func (w *Dumper) Write(p []byte) (i int, err error) { //nolint:gocognit
 	return i, err
 }
 
+// Copied returns the output offset the last Copy is from and the bytes it expands to.
+// It's for Debug callback with KeepWindow set.
+// Zero region copies have src equal to their own offset.
+// p is valid until the callback returns.
+func (w *Dumper) Copied() (src int64, p []byte) {
+	return w.src, w.copied
+}
+
+// checkCopy checks the copy fits the window the same way Reader does.
+func (w *Dumper) checkCopy(l, off int) error {
+	if w.BlockSizeLimit != 0 && l > w.BlockSizeLimit {
+		return ErrBlockSizeOverLimit
+	}
+
+	if w.win == nil {
+		w.resetWindow(bits.TrailingZeros(DefaultBlockSize)) // stream without header
+	}
+
+	if off > len(w.win) {
+		return ErrOverflow
+	}
+
+	return nil
+}
+
+func (w *Dumper) resetWindow(bs int) {
+	w.wpos = 0
+
+	if len(w.win) != 1<<bs {
+		w.win = make([]byte, 1<<bs)
+		return
+	}
+
+	for i := 0; i < len(w.win); {
+		i += copy(w.win[i:], zeros)
+	}
+}
+
+func (w *Dumper) writeWindow(p []byte) {
+	if w.win == nil {
+		w.resetWindow(bits.TrailingZeros(DefaultBlockSize)) // stream without header
+	}
+
+	mask := int64(len(w.win) - 1)
+
+	for _, c := range p {
+		w.win[w.wpos&mask] = c
+		w.wpos++
+	}
+}
+
+func (w *Dumper) copyWindow(l, off int) {
+	mask := int64(len(w.win) - 1)
+	w.copied = w.copied[:0]
+
+	w.src = w.r.pos
+	if off != 0 {
+		w.src -= int64(off)
+	}
+
+	for i := 0; i < l; i++ {
+		var c byte
+
+		if off != 0 {
+			c = w.win[(w.wpos-int64(off))&mask]
+		}
+
+		w.win[w.wpos&mask] = c
+		w.wpos++
+
+		w.copied = append(w.copied, c)
+	}
+}
+
 func (w *Dumper) Close() error {
 	i := 0
 
@@ -0,0 +1,376 @@
+package eazy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type (
+	// Report is the Validate result.
+	Report struct {
+		In  int64 // compressed size
+		Out int64 // decompressed size
+
+		Streams  int // stream headers
+		Literals int
+		Copies   int // including zero regions
+		Zeros    int // zero regions
+		Metas    int // including stream headers and breaks
+		Breaks   int
+		Padding  int64 // padding bytes
+
+		// Problems which don't prevent walking the stream further.
+		// Validate returns the first one as an error.
+		NonCanonical int // elements encoded longer than necessary
+		UnknownMetas int
+
+		// BeforeStart is the number of copies reaching before the stream start
+		// into the zero filled window. It's only a problem in Strict mode,
+		// Writer produces such copies when data contains zero bytes.
+		BeforeStart int
+	}
+
+	// ValidateOptions configures Validate.
+	ValidateOptions struct {
+		Options
+
+		// Strict makes copies reaching before the stream start a problem.
+		// Reader accepts them and Writer produces them for data with zero bytes,
+		// so it's for pipelines which accept only streams without them.
+		Strict bool
+	}
+)
+
+var (
+	ErrNonCanonical     = errors.New("non-canonical encoding")
+	ErrCopyBeforeStart  = errors.New("copy before the stream start")
+	errValidateFinished = errors.New("finished")
+)
+
+// Validate walks the compressed stream checking every element without decoding it.
+// Literal data is skipped, so it's much cheaper than decompression.
+//
+// It checks everything Reader configured by opts would check,
+// and in addition it flags length, offset and meta encodings longer than necessary.
+// Writer never produces such elements, but Reader accepts them.
+// Copies reaching before the stream start are counted,
+// and they are flagged in opts.Strict mode.
+// Unknown metas are counted even if opts.SkipUnsupportedMeta is set,
+// but they are not a problem in that case.
+//
+// Format has no checksums, so data itself is not verified.
+//
+// Problems are counted in Report and the first one is returned as CorruptError,
+// or as LimitError if a block or an element is bigger than opts.BlockSizeLimit.
+// Errors which Reader can't continue after stop the walk.
+func Validate(r io.Reader, vopts ValidateOptions) (rep Report, err error) {
+	opts, err := vopts.Options.withDefaults()
+	if err != nil {
+		return rep, err
+	}
+
+	v := validator{
+		s: Reader{
+			Reader:          r,
+			BufferSize:      opts.BufferSize,
+			BufferSizeLimit: opts.BufferSizeLimit,
+		},
+		opts:   opts,
+		strict: vopts.Strict,
+		pos:    -1,
+	}
+
+	if v.s.BufferSizeLimit < 0 {
+		v.s.BufferSizeLimit = 0
+	}
+
+	defer v.s.Release()
+
+	err = v.run()
+	if errors.Is(err, errValidateFinished) {
+		err = nil
+	}
+
+	if err == nil {
+		err = v.first
+	}
+
+	v.rep.In = v.s.boff + int64(v.s.i)
+
+	return v.rep, err
+}
+
+type validator struct {
+	s      Reader
+	opts   Options
+	strict bool
+	rep    Report
+
+	bs  int
+	pos int64 // output since the stream start, -1 before the first header
+
+	skip int64 // literal data left
+
+	first error
+}
+
+func (v *validator) run() error {
+	s := &v.s
+
+	for {
+		if v.skip != 0 {
+			n := int64(len(s.b) - s.i)
+			if n > v.skip {
+				n = v.skip
+			}
+
+			s.i += int(n)
+			v.skip -= n
+
+			if v.skip != 0 {
+				err := v.more("literal")
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		st := s.i
+
+		for st < len(s.b) && s.b[st] == Padding {
+			st++
+		}
+
+		v.rep.Padding += int64(st - s.i)
+		s.i = st
+
+		e, i, err := parseElement(s.d, s.b, s.i)
+		if errors.Is(err, ErrShortBuffer) {
+			err = v.more("")
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+		if err != nil {
+			return v.corrupt(err, tagKind(s.b, st))
+		}
+
+		if s.boff == 0 && st == 0 && v.opts.RequireMagic && (e.tag != 'm' || e.off != MetaMagic) {
+			return v.corrupt(ErrNoMagic, tagKind(s.b, st))
+		}
+
+		switch e.tag {
+		case 'm':
+			err = v.meta(e, s.b[i-e.l:i], i-e.l-e.st)
+		case 'l':
+			err = v.literal(e, i-e.st)
+		default:
+			err = v.copy(e, i-e.st)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		s.i = i
+	}
+}
+
+func (v *validator) meta(e element, p []byte, hdr int) (err error) {
+	v.rep.Metas++
+
+	if hdr > metaSize(e.l) {
+		v.problem(&v.rep.NonCanonical, ErrNonCanonical, "meta")
+	}
+
+	tagLen := [...]int{4, 1, 1, 0}
+
+	if j := e.off >> 3; j < len(tagLen) && e.l != tagLen[j] {
+		return v.corrupt(ErrUnsupportedMeta, "meta")
+	}
+
+	switch e.off {
+	case MetaMagic:
+		if !bytes.Equal(p, []byte("eazy")) {
+			return v.corrupt(ErrBadMagic, "meta")
+		}
+	case MetaVer:
+		if int(p[0]) > Version {
+			return v.corrupt(fmt.Errorf("%w: %v", ErrUnsupportedVersion, p[0]), "meta")
+		}
+	case MetaReset:
+		bs := int(p[0])
+		if bs > 32 {
+			return v.corrupt(ErrOverflow, "meta")
+		}
+		if v.opts.BlockSizeLimit > 0 && 1<<bs > v.opts.BlockSizeLimit {
+			return v.limit(ErrBlockSizeOverLimit)
+		}
+
+		v.bs = 1 << bs
+		v.pos = 0
+		v.rep.Streams++
+	case MetaBreak:
+		v.rep.Breaks++
+	default:
+		v.rep.UnknownMetas++
+
+		if !v.opts.SkipUnsupportedMeta {
+			v.problem(nil, fmt.Errorf("%w: 0x%x", ErrUnsupportedMeta, e.off), "meta")
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) literal(e element, hdr int) error {
+	v.rep.Literals++
+
+	if err := v.data(e, "literal"); err != nil {
+		return err
+	}
+
+	if hdr > varSize(e.l, Len1) {
+		v.problem(&v.rep.NonCanonical, ErrNonCanonical, "literal")
+	}
+
+	v.advance(e.l)
+	v.skip = int64(e.l)
+
+	return nil
+}
+
+func (v *validator) copy(e element, hdr int) error {
+	v.rep.Copies++
+
+	if err := v.data(e, "copy"); err != nil {
+		return err
+	}
+
+	if e.off > v.bs {
+		return v.corrupt(ErrOverflow, "copy")
+	}
+
+	if e.off == 0 {
+		v.rep.Zeros++
+	} else if int64(e.off) > v.pos {
+		if v.strict {
+			v.problem(&v.rep.BeforeStart, ErrCopyBeforeStart, "copy")
+		} else {
+			v.rep.BeforeStart++
+		}
+	}
+
+	size := varSize(e.l, Len1)
+
+	if e.off >= e.l {
+		size += varSize(e.off-e.l, Off1)
+	} else {
+		size += 1 + varSize(e.off, Off1)
+	}
+
+	if hdr > size {
+		v.problem(&v.rep.NonCanonical, ErrNonCanonical, "copy")
+	}
+
+	v.advance(e.l)
+
+	return nil
+}
+
+// data checks common literal and copy conditions.
+func (v *validator) data(e element, kind string) error {
+	if v.pos < 0 {
+		return v.corrupt(ErrMissedMeta, kind)
+	}
+
+	if v.opts.BlockSizeLimit > 0 && e.l > v.opts.BlockSizeLimit {
+		return v.limit(ErrBlockSizeOverLimit)
+	}
+
+	return nil
+}
+
+func (v *validator) advance(l int) {
+	v.pos += int64(l)
+	v.rep.Out += int64(l)
+}
+
+func (v *validator) more(kind string) error {
+	s := &v.s
+
+	err := s.more()
+	if !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if v.skip != 0 || s.i < len(s.b) {
+		if kind == "" {
+			kind = tagKind(s.b, s.i)
+		}
+
+		return v.corrupt(io.ErrUnexpectedEOF, kind)
+	}
+
+	return errValidateFinished
+}
+
+// problem counts a problem and remembers the first one.
+func (v *validator) problem(cnt *int, err error, kind string) {
+	if cnt != nil {
+		*cnt++
+	}
+
+	if v.first == nil {
+		v.first = v.corrupt(err, kind)
+	}
+}
+
+// limit returns LimitError, it's not a corruption.
+func (v *validator) limit(err error) error {
+	return &LimitError{
+		Err: err,
+		In:  v.s.boff + int64(v.s.i),
+		Out: v.rep.Out,
+	}
+}
+
+func (v *validator) corrupt(err error, kind string) error {
+	return &CorruptError{
+		Err:  err,
+		In:   v.s.boff + int64(v.s.i),
+		Out:  v.rep.Out,
+		Kind: kind,
+	}
+}
+
+// varSize returns the canonical encoded size of a length or an offset
+// with values below emb embedded into the first byte.
+func varSize(x, emb int) int {
+	switch {
+	case x < emb:
+		return 1
+	case x < emb+0x100:
+		return 2
+	case x < emb+0x100+0x1_0000:
+		return 3
+	default:
+		return 5
+	}
+}
+
+// metaSize returns the canonical meta header size for data length l.
+func metaSize(l int) int {
+	switch {
+	case l == 0 || l < MetaLenWide && l&(l-1) == 0:
+		return 2
+	default:
+		return 2 + varSize(l, Off1)
+	}
+}
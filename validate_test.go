@@ -0,0 +1,142 @@
+package eazy
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	data := testLogData(200 * KiB)
+
+	var b Buf
+
+	w := NewWriter(&b, 16*KiB, 256)
+
+	for i := 0; i < len(data); i += 10 * KiB {
+		_, err := w.Write(data[i : i+10*KiB])
+		require.NoError(t, err)
+
+		err = w.WriteBreak()
+		require.NoError(t, err)
+
+		if i == 100*KiB {
+			w.Reset(&b)
+		}
+
+		b = append(b, Padding)
+	}
+
+	_, err := w.Write(make([]byte, 1000))
+	require.NoError(t, err)
+
+	rep, err := Validate(bytes.NewReader(b), ValidateOptions{Options: DefaultOptions()})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(len(b)), rep.In)
+	assert.Equal(t, int64(len(data)+1000), rep.Out)
+	assert.Equal(t, 2, rep.Streams)
+	assert.Equal(t, 20, rep.Breaks)
+	assert.Equal(t, int64(20), rep.Padding)
+	assert.Equal(t, 1, rep.Zeros)
+	assert.NotZero(t, rep.Literals)
+	assert.NotZero(t, rep.Copies)
+	assert.Zero(t, rep.NonCanonical+rep.UnknownMetas)
+
+	_, err = Validate(bytes.NewReader(b[:len(b)-2]), ValidateOptions{Options: DefaultOptions()})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestValidateZeros(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+
+	var b Buf
+	var before, strict int
+
+	w := NewWriter(&b, 1*KiB, 256)
+	data := make([]byte, 2*KiB)
+
+	for i := 0; i < 200; i++ {
+		for j := range data {
+			data[j] = byte(rnd.Intn(4)) // a lot of zeros
+		}
+
+		b = b[:0]
+		w.Reset(&b)
+
+		_, err := w.Write(data)
+		require.NoError(t, err)
+
+		rep, err := Validate(bytes.NewReader(b), ValidateOptions{Options: DefaultOptions()})
+		require.NoError(t, err, "iter %d", i)
+
+		before += rep.BeforeStart
+
+		srep, err := Validate(bytes.NewReader(b), ValidateOptions{Options: DefaultOptions(), Strict: true})
+		assert.Equal(t, rep, srep)
+
+		if rep.BeforeStart != 0 {
+			assert.ErrorIs(t, err, ErrCopyBeforeStart)
+			strict++
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+
+	assert.NotZero(t, before, "copies before the start are expected to be produced")
+	assert.NotZero(t, strict)
+}
+
+func TestValidateProblems(t *testing.T) {
+	var b Buf
+
+	w := NewWriter(&b, 1024, 32)
+
+	_, err := w.Write([]byte("0123456789"))
+	require.NoError(t, err)
+
+	lit := len(b)
+
+	b = append(b, Copy|4, OffLong, 8)             // long offset form for off >= l
+	b = append(b, Meta, MetaBreak|MetaLenWide, 0) // wide length for 0
+	b = w.e.Meta(b, MetaTagMask, 0)               // unknown
+
+	b = w.e.Tag(b, Copy, 4)
+	b = w.e.Offset(b, 100, 4) // before the start
+
+	rep, err := Validate(bytes.NewReader(b), ValidateOptions{Options: DefaultOptions()})
+	assert.ErrorIs(t, err, ErrNonCanonical)
+
+	var cerr *CorruptError
+	if assert.ErrorAs(t, err, &cerr) {
+		assert.Equal(t, int64(lit), cerr.In)
+		assert.Equal(t, int64(10), cerr.Out)
+		assert.Equal(t, "copy", cerr.Kind)
+	}
+
+	assert.Equal(t, 2, rep.NonCanonical)
+	assert.Equal(t, 1, rep.BeforeStart)
+	assert.Equal(t, 1, rep.UnknownMetas)
+	assert.Equal(t, 1, rep.Breaks)
+	assert.Equal(t, int64(18), rep.Out)
+
+	// Reader accepts it all
+
+	r := NewReaderBytes(b)
+	r.SkipUnsupportedMeta = true
+
+	_, err = r.Read(make([]byte, 100))
+	assert.ErrorIs(t, err, ErrBreak)
+
+	_, err = io.ReadAll(r)
+	assert.NoError(t, err)
+
+	// no header
+
+	_, err = Validate(bytes.NewReader(b[len(w.appendHeader(nil)):]), ValidateOptions{Options: DefaultOptions()})
+	assert.ErrorIs(t, err, ErrMissedMeta)
+}
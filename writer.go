@@ -1,6 +1,8 @@
 package eazy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/bits"
@@ -33,10 +35,43 @@ type (
 		// The value can be changed between Writes, but not simultaneously.
 		FlushThreshold int
 
+		// Rollback makes Writer keep the stream consistent
+		// when the underlaying Write fails or is short.
+		//
+		// If none of the record has been written, Writer state is rolled back
+		// and Write returns 0 with the error, so the same record can be retried.
+		// If only a part of it has been written, the rest stays buffered
+		// to be written by the next Flush or Write.
+		// Write returns len(p) with the error in that case,
+		// which means the record is accepted and must not be repeated.
+		//
+		// Without Rollback Writer resets on error and starts a new stream with the next Write.
+		Rollback bool
+
+		// ChunkSize is the size of chunks ReadFrom reads input by.
+		// Each chunk is read in full before it's compressed with one Write,
+		// so compression ratio and flushes don't depend on the source read sizes.
+		// 0 means DefaultChunkSize.
+		ChunkSize int
+
+		// ChunkBreak makes ReadFrom write Break marker between chunks.
+		ChunkBreak bool
+
+		// ChunkFlush makes ReadFrom flush after each chunk
+		// regardless of FlushThreshold.
+		ChunkFlush bool
+
+		chunk []byte
+
 		// output
 		b       []byte
 		written int64
 
+		// rollback state
+		tx    int // len(b) before the current record, negative if it's partially written
+		txpos int64
+		undo  []byte // window part overwritten by the current record
+
 		block []byte
 		mask  int
 		pos   int64
@@ -121,6 +156,11 @@ const (
 
 var zeros = make([]byte, 1024)
 
+// maxLen is the longest length Encoder.Tag can encode.
+// Writer splits longer literals and copies into multiple elements.
+// It's a variable to be able to test splitting without allocating gigabytes.
+var maxLen = Len1 + 0x100 + 0x1_0000 + 0x1_0000_0000 - 8 - 1
+
 // NewWriter creates new compressor writing to wr, with block size,
 // and hash table size.
 //
@@ -130,6 +170,7 @@ var zeros = make([]byte, 1024)
 // 1 * eazy.MiB block and 1024 table size is a good starting point.
 //
 // Both block and table sizes must be a power of two.
+// NewWriter panics otherwise, use NewWriterOptions to get an error instead.
 func NewWriter(wr io.Writer, block, htable int) *Writer {
 	w := &Writer{
 		Writer:      wr,
@@ -152,19 +193,23 @@ func (w *Writer) Reset(wr io.Writer) {
 }
 
 // ResetSize recreates Writer reusing allocated objects.
-func (w *Writer) ResetSize(wr io.Writer, block, htable int) {
+// It returns an error and leaves Writer unchanged if sizes are not valid, see NewWriter.
+func (w *Writer) ResetSize(wr io.Writer, block, htable int) error {
+	err := checkSizes(block, htable)
+	if err != nil {
+		return err
+	}
+
 	w.Writer = wr
 	w.init(block, htable)
 	w.reset()
+
+	return nil
 }
 
 func (w *Writer) init(bs, hs int) {
-	if (bs-1)&bs != 0 || bs < 32 || bs > 1<<31 {
-		panic("block size must be a power of two (32 < bs < 1<<31)")
-	}
-
-	if (hs-1)&hs != 0 || hs < 4 {
-		panic("hash table size must be a power of two (hs >= 4)")
+	if err := checkSizes(bs, hs); err != nil {
+		panic(err)
 	}
 
 	w.mask = bs - 1
@@ -200,10 +245,15 @@ func (w *Writer) reset() {
 }
 
 // Write compresses p and writes result to underlaying writer.
+// p can be of any size, too long elements are split.
 //
 // One Write results in one Write with comressed data to the underlaying io.Writer.
 // Header meta is added to the first Write.
 func (w *Writer) Write(p []byte) (done int, err error) {
+	if w.Rollback {
+		w.save(len(p))
+	}
+
 	if w.isreset() {
 		w.b = w.appendHeader(w.b)
 	}
@@ -330,12 +380,73 @@ func (w *Writer) Write(p []byte) (done int, err error) {
 
 	err = w.write()
 	if err != nil {
-		return 0, err
+		return w.fail(len(p), err)
 	}
 
 	return done, nil
 }
 
+// ReadFrom implements io.ReaderFrom.
+// It reads r by ChunkSize chunks till io.EOF and compresses each one with Write.
+// See ChunkSize, ChunkBreak and ChunkFlush.
+//
+// The returned number is the number of bytes accepted by the Writer,
+// so it's less than read from r if Write fails.
+// Data read before r error is compressed before the error is returned.
+func (w *Writer) ReadFrom(r io.Reader) (n int64, err error) {
+	return w.ReadFromContext(context.Background(), r)
+}
+
+// ReadFromContext is ReadFrom which checks ctx before each chunk.
+// A blocked Read from r is not interrupted.
+func (w *Writer) ReadFromContext(ctx context.Context, r io.Reader) (n int64, err error) {
+	size := w.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+
+	if cap(w.chunk) < size {
+		w.chunk = make([]byte, size)
+	}
+
+	p := w.chunk[:size]
+
+	for chunk := 0; ; chunk++ {
+		if err = ctx.Err(); err != nil {
+			return n, err
+		}
+
+		m, rerr := readChunk(r, p)
+		if m == 0 && rerr != nil {
+			return n, eofNil(rerr)
+		}
+
+		if w.ChunkBreak && chunk != 0 {
+			err = w.WriteBreak()
+			if err != nil {
+				return n, err
+			}
+		}
+
+		m, err = w.Write(p[:m])
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+
+		if w.ChunkFlush {
+			err = w.Flush()
+			if err != nil {
+				return n, err
+			}
+		}
+
+		if rerr != nil {
+			return n, eofNil(rerr)
+		}
+	}
+}
+
 // WriteHeader manually triggers write of required header meta tags.
 // It's not required to call this method manually,
 // header is written automatically with the first Writer.Write.
@@ -344,9 +455,18 @@ func (w *Writer) WriteHeader() error {
 		return nil
 	}
 
+	if w.Rollback {
+		w.save(0)
+	}
+
 	w.b = w.appendHeader(w.b)
 
-	return w.write()
+	err := w.write()
+	if err != nil {
+		_, err = w.fail(0, err)
+	}
+
+	return err
 }
 
 // WriteBreak writes Break marker which can be used to separate chunks of data in the same compression stream.
@@ -356,13 +476,22 @@ func (w *Writer) WriteHeader() error {
 //
 // See ErrBreak for more information.
 func (w *Writer) WriteBreak() error {
+	if w.Rollback {
+		w.save(0)
+	}
+
 	if w.isreset() {
 		w.b = w.appendHeader(w.b)
 	}
 
 	w.b = append(w.b, Meta, MetaBreak|MetaLen0)
 
-	return w.write()
+	err := w.write()
+	if err != nil {
+		_, err = w.fail(0, err)
+	}
+
+	return err
 }
 
 // Flush flushes internal buffer.
@@ -388,10 +517,20 @@ func (w *Writer) flush() (err error) {
 	n, err := w.Writer.Write(w.b)
 	w.written += int64(n)
 
-	if err != nil || n != len(w.b) {
-		w.reset()
+	if err == nil && n != len(w.b) {
+		err = io.ErrShortWrite
 	}
+
+	if err != nil && w.Rollback {
+		w.b = w.b[:copy(w.b, w.b[n:])]
+		w.tx -= n
+
+		return err
+	}
+
 	if err != nil {
+		w.reset()
+
 		return err
 	}
 
@@ -400,6 +539,93 @@ func (w *Writer) flush() (err error) {
 	return nil
 }
 
+// fail handles the underlaying writer error for a record of l bytes.
+func (w *Writer) fail(l int, err error) (int, error) {
+	if !w.Rollback {
+		return 0, err
+	}
+
+	if w.tx < 0 {
+		return l, err
+	}
+
+	w.rollback()
+
+	return 0, err
+}
+
+// save remembers the state to roll back to if the next record of l bytes fails.
+func (w *Writer) save(l int) {
+	w.tx = len(w.b)
+	w.txpos = w.pos
+
+	if l > len(w.block) {
+		l = len(w.block)
+	}
+
+	w.undo = w.undo[:0]
+
+	for i := 0; i < l; {
+		st := (int(w.pos) + i) & w.mask
+
+		end := st + l - i
+		if end > len(w.block) {
+			end = len(w.block)
+		}
+
+		w.undo = append(w.undo, w.block[st:end]...)
+		i += end - st
+	}
+}
+
+func (w *Writer) rollback() {
+	l := w.pos - w.txpos
+
+	w.b = w.b[:w.tx]
+	w.pos = w.txpos
+
+	for i := 0; i < len(w.undo); {
+		i += copy(w.block[(int(w.pos)+i)&w.mask:], w.undo[i:])
+	}
+
+	// Hash table entries pointing to the rolled back positions
+	// would be taken as the data ahead of w.pos, so they are dropped.
+	// That's cheaper than saving the whole table for each Write.
+
+	txpos := uint32(w.txpos)
+
+	for i, pos := range w.ht {
+		if int64(pos-txpos) < l {
+			w.ht[i] = 0
+		}
+	}
+}
+
+// readChunk reads till p is full or an error.
+func readChunk(r io.Reader, p []byte) (n int, err error) {
+	for n < len(p) && err == nil {
+		var m int
+
+		m, err = r.Read(p[n:])
+		n += m
+	}
+
+	return n, err
+}
+
+// MemoryUsage returns the size of buffers owned by Writer.
+func (w *Writer) MemoryUsage() int {
+	return cap(w.b) + cap(w.block) + cap(w.ht)*int(unsafe.Sizeof(w.ht[0])) + cap(w.undo) + cap(w.chunk)
+}
+
+func eofNil(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	return err
+}
+
 func (w *Writer) isreset() bool {
 	return int(w.written)+len(w.b) == 0
 }
@@ -430,8 +656,7 @@ func (w *Writer) writeZeros(p []byte, done, i int) (nextdone, iend int) {
 		w.copyData(p, done, i)
 	}
 
-	w.b = w.e.Tag(w.b, Copy, iend-i)
-	w.b = append(w.b, OffLong, 0)
+	w.appendCopyOff(0, iend-i)
 
 	w.copyData(p, i, iend)
 
@@ -480,8 +705,7 @@ func (w *Writer) writeRunlen(p []byte, done, st, i int) (nextdone, iend int) {
 	w.appendLiteral(p, done, ist)
 	w.copyData(p, done, ist)
 
-	w.b = w.e.Tag(w.b, Copy, iend-ist)
-	w.b = w.e.Offset(w.b, i-st, iend-ist)
+	w.appendCopyOff(i-st, iend-ist)
 
 	w.copyData(p, ist, iend)
 
@@ -517,13 +741,38 @@ func (w *Writer) appendReset(b []byte, block int) []byte {
 }
 
 func (w *Writer) appendLiteral(d []byte, st, end int) {
-	w.b = w.e.Tag(w.b, Literal, end-st)
-	w.b = append(w.b, d[st:end]...)
+	for st < end {
+		l := end - st
+		if l > maxLen {
+			l = maxLen
+		}
+
+		w.b = w.e.Tag(w.b, Literal, l)
+		w.b = append(w.b, d[st:st+l]...)
+
+		st += l
+	}
 }
 
 func (w *Writer) appendCopy(st, end int) {
-	w.b = w.e.Tag(w.b, Copy, end-st)
-	w.b = w.e.Offset(w.b, int(w.pos)-st, end-st)
+	w.appendCopyOff(int(w.pos)-st, end-st)
+}
+
+// appendCopyOff appends copy of l bytes starting off bytes back.
+// Zero off is a zero bytes region.
+// Split elements have the same offset as the output position moves along with the source.
+func (w *Writer) appendCopyOff(off, l int) {
+	for l > 0 {
+		n := l
+		if n > maxLen {
+			n = maxLen
+		}
+
+		w.b = w.e.Tag(w.b, Copy, n)
+		w.b = w.e.Offset(w.b, off, n)
+
+		l -= n
+	}
 }
 
 func (w *Writer) copyData(d []byte, st, end int) {